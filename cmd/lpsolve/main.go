@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/unbleaklessness/go-diet/simplex"
+)
+
+const (
+	lpFormat   = "lp"
+	mpsFormat  = "mps"
+	jsonFormat = "json"
+
+	textOutput = "text"
+	jsonOutput = "json"
+)
+
+// bound is a bound of a JSON problem: a number, or "inf", "+inf" or "-inf".
+type bound float64
+
+func (b *bound) UnmarshalJSON(data []byte) error {
+
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		switch strings.ToLower(s) {
+		case "inf", "+inf", "infinity", "+infinity":
+			*b = bound(math.Inf(1))
+		case "-inf", "-infinity":
+			*b = bound(math.Inf(-1))
+		default:
+			return fmt.Errorf("invalid bound %q", s)
+		}
+		return nil
+	}
+
+	var x float64
+	if e := json.Unmarshal(data, &x); e != nil {
+		return e
+	}
+	*b = bound(x)
+
+	return nil
+}
+
+// jsonVariable is a variable of a JSON problem, bounded by
+// `0 <= x <= +Inf` unless its bounds are given.
+type jsonVariable struct {
+	Name    string
+	Lower   *bound
+	Upper   *bound
+	Integer bool
+}
+
+// jsonConstraint is a constraint of a JSON problem, `Lower <= Expr <= Upper`
+// where a bound that is not given bounds nothing. A positive Penalty makes
+// the constraint soft.
+type jsonConstraint struct {
+	Name    string
+	Expr    map[string]float64
+	Lower   *bound
+	Upper   *bound
+	Penalty float64
+}
+
+// jsonProblem is a problem described in JSON. Sense is "maximize" or
+// "minimize", and expressions refer to variables by name.
+type jsonProblem struct {
+	Sense       string
+	Objective   map[string]float64
+	Variables   []jsonVariable
+	Constraints []jsonConstraint
+}
+
+func orDefault(b *bound, x float64) float64 {
+	if b == nil {
+		return x
+	}
+	return float64(*b)
+}
+
+// readJSON reads a model from a JSON problem.
+func readJSON(r io.Reader) (*simplex.Model, error) {
+
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+
+	p := jsonProblem{}
+
+	e := decoder.Decode(&p)
+	if e != nil {
+		return nil, e
+	}
+
+	m := simplex.NewModel()
+
+	expr := func(terms map[string]float64) (simplex.Expr, error) {
+		expr := simplex.Expr{}
+		for name, x := range terms {
+			v, ok := m.VarByName(name)
+			if !ok {
+				return nil, fmt.Errorf("unknown variable %q", name)
+			}
+			expr[v] = x
+		}
+		return expr, nil
+	}
+
+	for _, v := range p.Variables {
+		lower, upper := orDefault(v.Lower, 0.0), orDefault(v.Upper, math.Inf(1))
+		if v.Integer {
+			m.AddIntVar(v.Name, lower, upper)
+		} else {
+			m.AddVar(v.Name, lower, upper)
+		}
+	}
+
+	for _, c := range p.Constraints {
+		lhs, e := expr(c.Expr)
+		if e != nil {
+			return nil, e
+		}
+		constraint := m.AddRange(c.Name, lhs, orDefault(c.Lower, math.Inf(-1)), orDefault(c.Upper, math.Inf(1)))
+		if c.Penalty != 0.0 {
+			m.SetPenalty(constraint, c.Penalty)
+		}
+	}
+
+	objective, e := expr(p.Objective)
+	if e != nil {
+		return nil, e
+	}
+
+	switch strings.ToLower(p.Sense) {
+	case "maximize", "max":
+		m.Maximize(objective)
+	case "minimize", "min", "":
+		m.Minimize(objective)
+	default:
+		return nil, fmt.Errorf("unknown sense %q", p.Sense)
+	}
+
+	return m, nil
+}
+
+// readModel reads a model in given format from path, or from the standard
+// input when path is "-".
+func readModel(path string, format string) (*simplex.Model, error) {
+
+	var r io.Reader = os.Stdin
+
+	if path != "-" {
+		data, e := ioutil.ReadFile(path)
+		if e != nil {
+			return nil, e
+		}
+		r = bytes.NewReader(data)
+	}
+
+	switch format {
+	case lpFormat:
+		return simplex.ReadLP(r)
+	case mpsFormat:
+		return simplex.ReadMPS(r)
+	case jsonFormat:
+		return readJSON(r)
+	}
+
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// namedValue is a value of a variable or a constraint in the JSON output.
+type namedValue struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+}
+
+// output is the JSON output of a solve. Values and duals are left out when
+// the solve does not report them.
+type output struct {
+	Status     string       `json:"status"`
+	Objective  *float64     `json:"objective,omitempty"`
+	Iterations int          `json:"iterations"`
+	Nodes      int          `json:"nodes,omitempty"`
+	Values     []namedValue `json:"values,omitempty"`
+	Duals      []namedValue `json:"duals,omitempty"`
+}
+
+// newOutput collects the outcome of a solve of m.
+func newOutput(m *simplex.Model, solution *simplex.Solution) output {
+
+	o := output{
+		Status:     solution.Status.String(),
+		Iterations: solution.Iterations,
+		Nodes:      solution.Nodes,
+	}
+
+	if solution.Values != nil {
+		objective := solution.Objective
+		o.Objective = &objective
+		for v := 0; v < m.NumVars(); v++ {
+			o.Values = append(o.Values, namedValue{m.VarName(simplex.Var(v)), solution.Value(simplex.Var(v))})
+		}
+	}
+
+	if solution.Duals != nil {
+		for c := 0; c < m.NumConstraints(); c++ {
+			o.Duals = append(o.Duals, namedValue{m.ConstraintName(simplex.Constraint(c)), solution.Dual(simplex.Constraint(c))})
+		}
+	}
+
+	return o
+}
+
+// printText writes the outcome of a solve as lines of text.
+func printText(w io.Writer, o output) {
+
+	fmt.Fprintf(w, "Status: %s\n", o.Status)
+	if o.Objective != nil {
+		fmt.Fprintf(w, "Objective: %g\n", *o.Objective)
+	}
+	fmt.Fprintf(w, "Iterations: %d\n", o.Iterations)
+	if o.Nodes > 0 {
+		fmt.Fprintf(w, "Nodes: %d\n", o.Nodes)
+	}
+
+	if len(o.Values) > 0 {
+		fmt.Fprintln(w, "Values:")
+		for _, v := range o.Values {
+			fmt.Fprintf(w, "%s %g\n", v.Name, v.Value)
+		}
+	}
+
+	if len(o.Duals) > 0 {
+		fmt.Fprintln(w, "Duals:")
+		for _, d := range o.Duals {
+			fmt.Fprintf(w, "%s %g\n", d.Name, d.Value)
+		}
+	}
+}
+
+var pricings = map[string]simplex.Pricing{
+	"dantzig":  simplex.Dantzig,
+	"bland":    simplex.Bland,
+	"steepest": simplex.SteepestEdge,
+}
+
+var methods = map[string]simplex.Method{
+	"simplex":  simplex.SimplexMethod,
+	"interior": simplex.InteriorPoint,
+}
+
+var scalings = map[string]simplex.Scaling{
+	"equilibration": simplex.Equilibration,
+	"none":          simplex.NoScaling,
+}
+
+func main() {
+
+	formatFlag := flag.String("format", "", "Format of the problem, `lp`, `mps` or `json`, by default given by the extension of the file")
+	outputFlag := flag.String("output", textOutput, "Print the solution as `text` or `json`")
+	pricingFlag := flag.String("pricing", "dantzig", "Pricing rule, `dantzig`, `bland` or `steepest`")
+	methodFlag := flag.String("method", "simplex", "Method that solves linear programs, `simplex` or `interior`")
+	crossoverFlag := flag.Bool("crossover", false, "Use with `-method interior` to move its solution to an optimal basis")
+	timeLimitFlag := flag.Float64("time-limit", 0.0, "Limit the solve to given number of seconds, 0 for no limit")
+	maxIterationsFlag := flag.Int("max-iterations", 0, "Limit the pivots of every linear program solved, and the iterations of the interior point method, 0 for the default")
+	scalingFlag := flag.String("scaling", "equilibration", "Scaling of the problem, `equilibration` or `none`")
+	feasibilityFlag := flag.Float64("feasibility-tolerance", 0.0, "How far values may be out of their bounds, 0 for the default")
+	optimalityFlag := flag.Float64("optimality-tolerance", 0.0, "How far reduced costs may be on the wrong side of zero at an optimum, 0 for the default")
+	pivotFlag := flag.Float64("pivot-tolerance", 0.0, "Smallest entry of a column to pivot on, 0 for the default")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] file\n\nSolves an LP, MPS or JSON problem, read from the standard input when file is `-`.\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	path := flag.Arg(0)
+
+	format := strings.ToLower(*formatFlag)
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+
+	pricing, ok := pricings[*pricingFlag]
+	if !ok {
+		fail(fmt.Errorf("unknown pricing %q", *pricingFlag))
+	}
+	method, ok := methods[*methodFlag]
+	if !ok {
+		fail(fmt.Errorf("unknown method %q", *methodFlag))
+	}
+	scaling, ok := scalings[*scalingFlag]
+	if !ok {
+		fail(fmt.Errorf("unknown scaling %q", *scalingFlag))
+	}
+	if *outputFlag != textOutput && *outputFlag != jsonOutput {
+		fail(fmt.Errorf("unknown output %q", *outputFlag))
+	}
+
+	m, e := readModel(path, format)
+	if e != nil {
+		fail(e)
+	}
+
+	// Interrupting the solve stops it like the time limit does.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		<-interrupt
+		cancel()
+	}()
+
+	solution, e := simplex.SolveContext(ctx, m, simplex.Options{
+		Pricing:       pricing,
+		Method:        method,
+		Crossover:     *crossoverFlag,
+		MaxIterations: *maxIterationsFlag,
+		TimeLimit:     time.Duration(*timeLimitFlag * float64(time.Second)),
+
+		Scaling:              scaling,
+		FeasibilityTolerance: *feasibilityFlag,
+		OptimalityTolerance:  *optimalityFlag,
+		PivotTolerance:       *pivotFlag,
+	})
+	if e != nil && !errors.Is(e, context.Canceled) {
+		fail(e)
+	}
+
+	o := newOutput(m, solution)
+
+	if *outputFlag == jsonOutput {
+		data, e := json.MarshalIndent(o, "", "    ")
+		if e != nil {
+			fail(e)
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	printText(os.Stdout, o)
+}
+
+// fail prints e and exits.
+func fail(e error) {
+	fmt.Fprintln(os.Stderr, "lpsolve:", e)
+	os.Exit(1)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/unbleaklessness/go-diet/simplex"
+)
+
+var update = flag.Bool("update", false, "Rewrite the golden outputs of testdata")
+
+// closeTo reports whether a and b agree to within 1e-7, relative to their
+// size when that is larger than one.
+func closeTo(a float64, b float64) bool {
+	return math.Abs(a-b) <= 1e-7*math.Max(1.0, math.Max(math.Abs(a), math.Abs(b)))
+}
+
+func compareValues(t *testing.T, kind string, got []namedValue, want []namedValue) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Errorf("%d %s, want %d", len(got), kind, len(want))
+		return
+	}
+	for i := range got {
+		if got[i].Name != want[i].Name || !closeTo(got[i].Value, want[i].Value) {
+			t.Errorf("%s %s = %g, want %s = %g", kind, got[i].Name, got[i].Value, want[i].Name, want[i].Value)
+		}
+	}
+}
+
+// TestGolden solves every problem of testdata with the default flags and
+// compares its JSON output with the golden file next to it. Iterations and
+// nodes are left out of the comparison, they change with any tuning of the
+// solver.
+func TestGolden(t *testing.T) {
+
+	var paths []string
+	for _, format := range []string{lpFormat, mpsFormat, jsonFormat} {
+		matches, e := filepath.Glob(filepath.Join("testdata", "*."+format))
+		if e != nil {
+			t.Fatal(e)
+		}
+		paths = append(paths, matches...)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no problems in testdata")
+	}
+
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+
+			m, e := readModel(path, strings.TrimPrefix(filepath.Ext(path), "."))
+			if e != nil {
+				t.Fatal(e)
+			}
+			solution, e := simplex.SolveContext(context.Background(), m, simplex.Options{
+				Pricing: pricings["dantzig"],
+				Method:  methods["simplex"],
+				Scaling: scalings["equilibration"],
+			})
+			if e != nil {
+				t.Fatal(e)
+			}
+			got := newOutput(m, solution)
+
+			golden := path + ".golden"
+			if *update {
+				data, e := json.MarshalIndent(got, "", "    ")
+				if e != nil {
+					t.Fatal(e)
+				}
+				if e := ioutil.WriteFile(golden, append(data, '\n'), 0644); e != nil {
+					t.Fatal(e)
+				}
+				return
+			}
+
+			data, e := ioutil.ReadFile(golden)
+			if e != nil {
+				t.Fatal(e)
+			}
+			var want output
+			if e := json.Unmarshal(data, &want); e != nil {
+				t.Fatal(e)
+			}
+
+			if got.Status != want.Status {
+				t.Fatalf("status %s, want %s", got.Status, want.Status)
+			}
+			if (got.Objective == nil) != (want.Objective == nil) {
+				t.Fatalf("objective %v, want %v", got.Objective, want.Objective)
+			}
+			if got.Objective != nil && !closeTo(*got.Objective, *want.Objective) {
+				t.Errorf("objective %g, want %g", *got.Objective, *want.Objective)
+			}
+			compareValues(t, "value", got.Values, want.Values)
+			compareValues(t, "dual", got.Duals, want.Duals)
+		})
+	}
+}
@@ -0,0 +1,25 @@
+* The cheapest amounts of three foods, in 100 grams, within ranges of kcals
+* and proteins and at least some calcium.
+NAME diet
+ROWS
+ N cost
+ G kcals
+ G proteins
+ G calcium
+COLUMNS
+    oats cost 0.3 kcals 380
+    oats proteins 13 calcium 50
+    milk cost 0.1 kcals 60
+    milk proteins 3.4 calcium 120
+    beans cost 0.4 kcals 340
+    beans proteins 21 calcium 140
+RHS
+    rhs kcals 2000 proteins 60
+    rhs calcium 1000
+RANGES
+    rng kcals 500 proteins 40
+BOUNDS
+ UP bnd milk 10
+ LO bnd beans 0.5
+ UP bnd beans 3
+ENDATA
@@ -2,16 +2,21 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"math"
+	"math/big"
 	"math/rand"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
-	"sync/atomic"
 	"time"
 
 	"github.com/unbleaklessness/go-diet/simplex"
@@ -23,6 +28,20 @@ type product struct {
 	Maximum float64
 	Minimum float64
 
+	// Portion is the amount in grams a product is preferably eaten in. Zero
+	// has no preference.
+	Portion float64
+
+	// Unit is the weight in grams of a piece of a product that only comes
+	// whole, such as an egg or a pill. Zero allows any amount.
+	Unit float64
+
+	// Group is the kind of a product, such as "red meat", that weekly group
+	// limits apply to.
+	Group string
+
+	Price float64
+
 	Description string
 
 	Kcals    float64
@@ -69,6 +88,35 @@ type diet = [][]dietEntry
 const (
 	jsonExtension = ".json"
 
+	macrosObjective     = "macros"
+	costObjective       = "cost"
+	violationsObjective = "violations"
+	varietyObjective    = "variety"
+	balanceObjective    = "balance"
+	portionsObjective   = "portions"
+
+	// objectiveTolerance is how much later objectives may worsen each
+	// objective before them, relative to its optimum.
+	objectiveTolerance = 0.001
+
+	// gapTolerance is the gap between a diet and the bound on its objective,
+	// relative to the objective, at which the search takes the diet as
+	// optimal. Later objectives may worsen it by objectiveTolerance all the
+	// same, and soft targets leave the bound weak enough that a closer gap is
+	// rarely proven in time.
+	gapTolerance = objectiveTolerance
+
+	// certifyTolerance is how far amounts may miss a bound, relative to the
+	// bound, and still meet it but for rounding. Solves round far less.
+	certifyTolerance = 1e-6
+
+	simplexMethod  = "simplex"
+	interiorMethod = "interior"
+
+	// leastAmount is the fewest grams of a product that count as eating it
+	// when the product has no Minimum of its own.
+	leastAmount = 10.0
+
 	lowerKcals    = 3000.0
 	lowerProteins = lowerKcals * 0.15
 	lowerCarbs    = lowerKcals * 0.55
@@ -83,17 +131,19 @@ const (
 	lowerVitaminA = 3000.0 // IU/Day.
 	upperVitaminA = 7000.0
 
-	lowerThiamin = 1.2                    // MG/Day.
-	upperThiamin = lowerThiamin * 10000.0 // No upper bound.
+	upperWeeklyVitaminA = 5000.0 // IU/Day on average over the week.
+
+	redMeat            = "red meat"
+	upperWeeklyRedMeat = 700.0 // Grams/Week.
+
+	lowerThiamin = 1.2 // MG/Day, no upper bound.
 
-	lowerRiboflavin = 1.3                       // MG/Day.
-	upperRiboflavin = lowerRiboflavin * 10000.0 // No upper bound.
+	lowerRiboflavin = 1.3 // MG/Day, no upper bound.
 
 	lowerNiacin = 16.0 // MG/Day.
 	upperNiacin = 35.0
 
-	lowerPantothenicAcid = 5.0                            // MG/Day.
-	upperPantothenicAcid = lowerPantothenicAcid * 10000.0 // No upper bound.
+	lowerPantothenicAcid = 5.0 // MG/Day, no upper bound.
 
 	lowerVitaminB6 = 1.3 // MG/Day.
 	upperVitaminB6 = 100.0
@@ -113,20 +163,17 @@ const (
 	lowerVitaminE = 5.0   // MG/Day, should be 15.0.
 	upperVitaminE = 125.0 // Clear upper bound is unkown, somewhere around 150.0.
 
-	lowerVitaminK = 120.0                   // MCG/Day.
-	upperVitaminK = lowerVitaminK * 10000.0 // No upper bound.
+	lowerVitaminK = 120.0 // MCG/Day, no upper bound.
 
 	lowerCalcium = 1000.0 // MG/Day.
 	upperCalcium = 2500.0
 
-	lowerMagnesium = 420.0                    // MG/Day.
-	upperMagnesium = lowerMagnesium * 10000.0 // Clear upper bound is unkown.
+	lowerMagnesium = 420.0 // MG/Day, clear upper bound is unkown.
 
 	lowerPhosphorus = 700.0 // MG/Day.
 	upperPhosphorus = 4000.0
 
-	lowerPotassium = 4700.0                   // MG/Day.
-	upperPotassium = lowerPotassium * 10000.0 // Clear upper bound is unkown.
+	lowerPotassium = 4700.0 // MG/Day, clear upper bound is unkown.
 
 	lowerSodium = 1500.0 // MG/Day.
 	upperSodium = 2300.0
@@ -380,498 +427,1604 @@ func totalMicronutrients(entries []dietEntry, isConsumed bool) map[micronutrient
 	return micronutrients
 }
 
-func dayDiet(products []product) ([]dietEntry, bool) {
-
-	nOptimizationColumns := len(products)
-
-	commonOffset := 25
-
-	ltOffset := commonOffset
-	nLTConstraints := ltOffset + len(products)
-	ltConstraintsLHS := make([][]float64, nLTConstraints)
-	ltConstraintsRHS := make([]float64, nLTConstraints)
-	for i := 0; i < nLTConstraints; i++ {
-		ltConstraintsLHS[i] = make([]float64, nOptimizationColumns)
-	}
-
-	for i := 0; i < len(products); i++ {
-		ltConstraintsLHS[0][i] = products[i].Kcals
-		ltConstraintsLHS[1][i] = products[i].Proteins * 4.0
-		ltConstraintsLHS[2][i] = products[i].Carbs * 4.0
-		ltConstraintsLHS[3][i] = products[i].Fats * 9.0
-		ltConstraintsLHS[4][i] = products[i].VitaminA
-		ltConstraintsLHS[5][i] = products[i].Thiamin
-		ltConstraintsLHS[6][i] = products[i].Riboflavin
-		ltConstraintsLHS[7][i] = products[i].Niacin
-		ltConstraintsLHS[8][i] = products[i].PantothenicAcid
-		ltConstraintsLHS[9][i] = products[i].VitaminB6
-		ltConstraintsLHS[10][i] = products[i].Folate
-		ltConstraintsLHS[11][i] = products[i].VitaminB12
-		ltConstraintsLHS[12][i] = products[i].VitaminC
-		ltConstraintsLHS[13][i] = products[i].VitaminD
-		ltConstraintsLHS[14][i] = products[i].VitaminE
-		ltConstraintsLHS[15][i] = products[i].VitaminK
-		ltConstraintsLHS[16][i] = products[i].Calcium
-		ltConstraintsLHS[17][i] = products[i].Magnesium
-		ltConstraintsLHS[18][i] = products[i].Phosphorus
-		ltConstraintsLHS[19][i] = products[i].Potassium
-		ltConstraintsLHS[20][i] = products[i].Sodium
-		ltConstraintsLHS[21][i] = products[i].Copper
-		ltConstraintsLHS[22][i] = products[i].Iron
-		ltConstraintsLHS[23][i] = products[i].Manganese
-		ltConstraintsLHS[24][i] = products[i].Zinc
-	}
-	ltConstraintsRHS[0] = upperKcals
-	ltConstraintsRHS[1] = upperProteins
-	ltConstraintsRHS[2] = upperCarbs
-	ltConstraintsRHS[3] = upperFats
-	ltConstraintsRHS[4] = upperVitaminA
-	ltConstraintsRHS[5] = upperThiamin
-	ltConstraintsRHS[6] = upperRiboflavin
-	ltConstraintsRHS[7] = upperNiacin
-	ltConstraintsRHS[8] = upperPantothenicAcid
-	ltConstraintsRHS[9] = upperVitaminB6
-	ltConstraintsRHS[10] = upperFolate
-	ltConstraintsRHS[11] = upperVitaminB12
-	ltConstraintsRHS[12] = upperVitaminC
-	ltConstraintsRHS[13] = upperVitaminD
-	ltConstraintsRHS[14] = upperVitaminE
-	ltConstraintsRHS[15] = upperVitaminK
-	ltConstraintsRHS[16] = upperCalcium
-	ltConstraintsRHS[17] = upperMagnesium
-	ltConstraintsRHS[18] = upperPhosphorus
-	ltConstraintsRHS[19] = upperPotassium
-	ltConstraintsRHS[20] = upperSodium
-	ltConstraintsRHS[21] = upperCopper
-	ltConstraintsRHS[22] = upperIron
-	ltConstraintsRHS[23] = upperManganese
-	ltConstraintsRHS[24] = upperZinc
-
-	for i := 0; i < len(products); i++ {
-		j := i + ltOffset
-		ltConstraintsLHS[j][i] = 100.0
-		ltConstraintsRHS[j] = products[i].Maximum
-	}
-
-	gtOffset := commonOffset
-	nGTConstraints := gtOffset + len(products)
-	gtConstraintsLHS := make([][]float64, nGTConstraints)
-	gtConstraintsRHS := make([]float64, nGTConstraints)
-	for i := 0; i < nGTConstraints; i++ {
-		gtConstraintsLHS[i] = make([]float64, nOptimizationColumns)
-	}
-
-	for i := 0; i < len(products); i++ {
-		gtConstraintsLHS[0][i] = products[i].Kcals
-		gtConstraintsLHS[1][i] = products[i].Proteins * 4.0
-		gtConstraintsLHS[2][i] = products[i].Carbs * 4.0
-		gtConstraintsLHS[3][i] = products[i].Fats * 9.0
-		gtConstraintsLHS[4][i] = products[i].VitaminA
-		gtConstraintsLHS[5][i] = products[i].Thiamin
-		gtConstraintsLHS[6][i] = products[i].Riboflavin
-		gtConstraintsLHS[7][i] = products[i].Niacin
-		gtConstraintsLHS[8][i] = products[i].PantothenicAcid
-		gtConstraintsLHS[9][i] = products[i].VitaminB6
-		gtConstraintsLHS[10][i] = products[i].Folate
-		gtConstraintsLHS[11][i] = products[i].VitaminB12
-		gtConstraintsLHS[12][i] = products[i].VitaminC
-		gtConstraintsLHS[13][i] = products[i].VitaminD
-		gtConstraintsLHS[14][i] = products[i].VitaminE
-		gtConstraintsLHS[15][i] = products[i].VitaminK
-		gtConstraintsLHS[16][i] = products[i].Calcium
-		gtConstraintsLHS[17][i] = products[i].Magnesium
-		gtConstraintsLHS[18][i] = products[i].Phosphorus
-		gtConstraintsLHS[19][i] = products[i].Potassium
-		gtConstraintsLHS[20][i] = products[i].Sodium
-		gtConstraintsLHS[21][i] = products[i].Copper
-		gtConstraintsLHS[22][i] = products[i].Iron
-		gtConstraintsLHS[23][i] = products[i].Manganese
-		gtConstraintsLHS[24][i] = products[i].Zinc
-	}
-	gtConstraintsRHS[0] = lowerKcals
-	gtConstraintsRHS[1] = lowerProteins
-	gtConstraintsRHS[2] = lowerCarbs
-	gtConstraintsRHS[3] = lowerFats
-	gtConstraintsRHS[4] = lowerVitaminA
-	gtConstraintsRHS[5] = lowerThiamin
-	gtConstraintsRHS[6] = lowerRiboflavin
-	gtConstraintsRHS[7] = lowerNiacin
-	gtConstraintsRHS[8] = lowerPantothenicAcid
-	gtConstraintsRHS[9] = lowerVitaminB6
-	gtConstraintsRHS[10] = lowerFolate
-	gtConstraintsRHS[11] = lowerVitaminB12
-	gtConstraintsRHS[12] = lowerVitaminC
-	gtConstraintsRHS[13] = lowerVitaminD
-	gtConstraintsRHS[14] = lowerVitaminE
-	gtConstraintsRHS[15] = lowerVitaminK
-	gtConstraintsRHS[16] = lowerCalcium
-	gtConstraintsRHS[17] = lowerMagnesium
-	gtConstraintsRHS[18] = lowerPhosphorus
-	gtConstraintsRHS[19] = lowerPotassium
-	gtConstraintsRHS[20] = lowerSodium
-	gtConstraintsRHS[21] = lowerCopper
-	gtConstraintsRHS[22] = lowerIron
-	gtConstraintsRHS[23] = lowerManganese
-	gtConstraintsRHS[24] = lowerZinc
-
-	for i := 0; i < len(products); i++ {
-		j := i + gtOffset
-		gtConstraintsLHS[j][i] = 100.0
-		gtConstraintsRHS[j] = products[i].Minimum
-	}
-
-	objective := make([]float64, nOptimizationColumns)
-	for i := range products {
-		proteins := products[i].Proteins * 4.0
-		carbs := products[i].Carbs * 4.0
-		fats := products[i].Fats * 9.0
-		objective[i] = products[i].Kcals + proteins + carbs + fats
+// unbounded is the upper bound of targets that have none.
+var unbounded = math.Inf(1)
+
+// target is a daily bound on the amount of a nutrient per 100 grams of
+// products. A target with a penalty is soft: it may be missed at the penalty
+// per unit of the nutrient it is missed by.
+type target struct {
+	name    string
+	lower   float64
+	upper   float64
+	amount  func(p product) float64
+	penalty float64
+}
+
+var targets = []target{
+	{"Kcals", lowerKcals, upperKcals, func(p product) float64 { return p.Kcals }, 0.0},
+	{"Proteins", lowerProteins, upperProteins, func(p product) float64 { return p.Proteins * 4.0 }, 0.0},
+	{"Carbs", lowerCarbs, upperCarbs, func(p product) float64 { return p.Carbs * 4.0 }, 0.0},
+	{"Fats", lowerFats, upperFats, func(p product) float64 { return p.Fats * 9.0 }, 0.0},
+	{"Vitamin A", lowerVitaminA, upperVitaminA, func(p product) float64 { return p.VitaminA }, 0.0},
+	{"Thiamin", lowerThiamin, unbounded, func(p product) float64 { return p.Thiamin }, 0.0},
+	{"Riboflavin", lowerRiboflavin, unbounded, func(p product) float64 { return p.Riboflavin }, 0.0},
+	{"Niacin", lowerNiacin, upperNiacin, func(p product) float64 { return p.Niacin }, 0.0},
+	{"Pantothenic Acid", lowerPantothenicAcid, unbounded, func(p product) float64 { return p.PantothenicAcid }, 0.0},
+	{"Vitamin B6", lowerVitaminB6, upperVitaminB6, func(p product) float64 { return p.VitaminB6 }, 0.0},
+	{"Folate", lowerFolate, upperFolate, func(p product) float64 { return p.Folate }, 0.0},
+	{"Vitamin B12", lowerVitaminB12, upperVitaminB12, func(p product) float64 { return p.VitaminB12 }, 0.0},
+	{"Vitamin C", lowerVitaminC, upperVitaminC, func(p product) float64 { return p.VitaminC }, 0.0},
+	{"Vitamin D", lowerVitaminD, upperVitaminD, func(p product) float64 { return p.VitaminD }, 0.0},
+	{"Vitamin E", lowerVitaminE, upperVitaminE, func(p product) float64 { return p.VitaminE }, 0.0},
+	{"Vitamin K", lowerVitaminK, unbounded, func(p product) float64 { return p.VitaminK }, 0.0},
+	{"Calcium", lowerCalcium, upperCalcium, func(p product) float64 { return p.Calcium }, 0.0},
+	{"Magnesium", lowerMagnesium, unbounded, func(p product) float64 { return p.Magnesium }, 0.0},
+	{"Phosphorus", lowerPhosphorus, upperPhosphorus, func(p product) float64 { return p.Phosphorus }, 0.0},
+	{"Potassium", lowerPotassium, unbounded, func(p product) float64 { return p.Potassium }, 0.0},
+	{"Sodium", lowerSodium, upperSodium, func(p product) float64 { return p.Sodium }, 0.0},
+	{"Copper", lowerCopper, upperCopper, func(p product) float64 { return p.Copper }, 0.0},
+	{"Iron", lowerIron, upperIron, func(p product) float64 { return p.Iron }, 0.0},
+	{"Manganese", lowerManganese, upperManganese, func(p product) float64 { return p.Manganese }, 0.0},
+	{"Zinc", lowerZinc, upperZinc, func(p product) float64 { return p.Zinc }, 0.0},
+}
+
+// weeklyTargets bound the average of a day over the week, alongside the daily
+// targets.
+var weeklyTargets = []target{
+	{"Vitamin A", lowerVitaminA, upperWeeklyVitaminA, func(p product) float64 { return p.VitaminA }, 0.0},
+}
+
+// groupLimits are the most grams of every group of products eaten in a week.
+var groupLimits = map[string]float64{
+	redMeat: upperWeeklyRedMeat,
+}
+
+// dayModel builds the linear program of a day: a variable per product in
+// units of 100 grams bounded by its Minimum and Maximum, and a constraint per
+// target. It returns the model with the stages of its objectives, see stages,
+// the first of which is also the objective of the model, so that it is the
+// one written to a file. When whole is set, products with a Unit get an
+// integer count of pieces their amount has to match. When optional is set,
+// amounts may drop to zero instead of their Minimum, see selectProducts.
+func dayModel(products []product, objectives []string, whole bool, optional bool) (*simplex.Model, []simplex.Var, []simplex.Constraint, []simplex.Objective) {
+
+	m := simplex.NewModel()
+
+	variables, _, constraints := addDay(m, "", products, whole, optional)
+
+	exprs := make([]simplex.Expr, len(objectives))
+	squares := make([][]simplex.Square, len(objectives))
+	for k, objective := range objectives {
+		exprs[k] = dayExpr(products, variables, objective)
+		squares[k] = daySquares(products, variables, objective)
 	}
 
-	amounts, _, ok := simplex.Simplex(
-		objective,
-		gtConstraintsLHS, gtConstraintsRHS,
-		ltConstraintsLHS, ltConstraintsRHS,
-		[][]float64{}, []float64{})
-	if !ok {
-		return []dietEntry{}, false
+	goals := stages(objectives, exprs, squares)
+	m.SetObjective(goals[0].Expr, goals[0].Sense)
+
+	return m, variables, constraints, goals
+}
+
+// addDay adds the variables and targets of a day described in dayModel to m,
+// named with given prefix. It returns the amounts, the pieces of products
+// counted in pieces and the target constraints of the day.
+func addDay(m *simplex.Model, prefix string, products []product, whole bool, optional bool) ([]simplex.Var, []simplex.Var, []simplex.Constraint) {
+
+	variables := make([]simplex.Var, len(products))
+	for i, p := range products {
+		lower := p.Minimum / 100.0
+		if optional {
+			lower = 0.0
+		}
+		variables[i] = m.AddVar(prefix+p.name, lower, p.Maximum/100.0)
 	}
 
-	dayDiet := []dietEntry{}
+	constraints := make([]simplex.Constraint, len(targets))
+	for i, t := range targets {
+		expr := simplex.Expr{}
+		for j, p := range products {
+			expr[variables[j]] = t.amount(p)
+		}
+		constraints[i] = m.AddRange(prefix+t.name, expr, t.lower, t.upper)
+		if t.penalty > 0.0 {
+			m.SetPenalty(constraints[i], t.penalty)
+		}
+	}
 
-	for i, amount := range amounts {
-		if amount <= 0.0 {
+	pieces := make([]simplex.Var, len(products))
+	for i, p := range products {
+		if !whole || p.Unit <= 0.0 {
 			continue
 		}
-		p := dietEntry{
-			ID:      products[i].ID,
-			Amount:  amount,
-			product: products[i],
+		name := prefix + p.name + " pieces"
+		lower := p.Minimum / p.Unit
+		if optional {
+			lower = 0.0
 		}
-		dayDiet = append(dayDiet, p)
+		pieces[i] = m.AddIntVar(name, lower, p.Maximum/p.Unit)
+		m.AddConstraint(name, simplex.Expr{variables[i]: 100.0, pieces[i]: -p.Unit}, simplex.EQ, 0.0)
 	}
 
-	dl := 0.0001
+	return variables, pieces, constraints
+}
 
-	totalKcals, totalProteins, totalCarbs, totalFats := totalMacronutrients(dayDiet, false)
-	ok = totalKcals <= upperKcals+dl && totalKcals >= lowerKcals-dl &&
-		totalProteins <= upperProteins+dl && totalProteins >= lowerProteins-dl &&
-		totalCarbs <= upperCarbs+dl && totalCarbs >= lowerCarbs-dl &&
-		totalFats <= upperFats+dl && totalFats >= lowerFats-dl
-	if !ok {
-		return []dietEntry{}, false
-	}
-
-	micronutrients := totalMicronutrients(dayDiet, false)
-	ok = micronutrients[vitaminA] <= upperVitaminA+dl && micronutrients[vitaminA] >= lowerVitaminA-dl &&
-		micronutrients[thiamin] <= upperThiamin+dl && micronutrients[thiamin] >= lowerThiamin-dl &&
-		micronutrients[riboflavin] <= upperRiboflavin+dl && micronutrients[riboflavin] >= lowerRiboflavin-dl &&
-		micronutrients[niacin] <= upperNiacin+dl && micronutrients[niacin] >= lowerNiacin-dl &&
-		micronutrients[pantothenicAcid] <= upperPantothenicAcid+dl && micronutrients[pantothenicAcid] >= lowerPantothenicAcid-dl &&
-		micronutrients[vitaminB6] <= upperVitaminB6+dl && micronutrients[vitaminB6] >= lowerVitaminB6-dl &&
-		micronutrients[folate] <= upperFolate+dl && micronutrients[folate] >= lowerFolate-dl &&
-		micronutrients[vitaminB12] <= upperVitaminB12+dl && micronutrients[vitaminB12] >= lowerVitaminB12-dl &&
-		micronutrients[vitaminC] <= upperVitaminC+dl && micronutrients[vitaminC] >= lowerVitaminC-dl &&
-		micronutrients[vitaminD] <= upperVitaminD+dl && micronutrients[vitaminD] >= lowerVitaminD-dl &&
-		micronutrients[vitaminE] <= upperVitaminE+dl && micronutrients[vitaminE] >= lowerVitaminE-dl &&
-		micronutrients[vitaminK] <= upperVitaminK+dl && micronutrients[vitaminK] >= lowerVitaminK-dl &&
-		micronutrients[calcium] <= upperCalcium+dl && micronutrients[calcium] >= lowerCalcium-dl &&
-		micronutrients[magnesium] <= upperMagnesium+dl && micronutrients[magnesium] >= lowerMagnesium-dl &&
-		micronutrients[phosphorus] <= upperPhosphorus+dl && micronutrients[phosphorus] >= lowerPhosphorus-dl &&
-		micronutrients[potassium] <= upperPotassium+dl && micronutrients[potassium] >= lowerPotassium-dl &&
-		micronutrients[sodium] <= upperSodium+dl && micronutrients[sodium] >= lowerSodium-dl &&
-		micronutrients[copper] <= upperCopper+dl && micronutrients[copper] >= lowerCopper-dl &&
-		micronutrients[iron] <= upperIron+dl && micronutrients[iron] >= lowerIron-dl &&
-		micronutrients[manganese] <= upperManganese+dl && micronutrients[manganese] >= lowerManganese-dl &&
-		micronutrients[zinc] <= upperZinc+dl && micronutrients[zinc] >= lowerZinc-dl
-	if !ok {
-		return []dietEntry{}, false
+// dayExpr returns an objective over the amounts of products of a day: the
+// kcals and macronutrients to maximize or the price to minimize. Violations
+// of soft targets and variety do not depend on amounts, so their expression
+// is empty.
+func dayExpr(products []product, variables []simplex.Var, objective string) simplex.Expr {
+
+	expr := simplex.Expr{}
+
+	for i, p := range products {
+		switch objective {
+		case costObjective:
+			expr[variables[i]] = p.Price
+		case macrosObjective:
+			expr[variables[i]] = p.Kcals + p.Proteins*4.0 + p.Carbs*4.0 + p.Fats*9.0
+		}
 	}
 
-	return dayDiet, true
+	return expr
 }
 
-func pickRandomProducts(products []product, n int) []product {
+// daySquares returns the squares of a quadratic objective over the amounts of
+// products of a day: how far every target with a lower and an upper bound is
+// from the middle of them for balance, and how far every product with a
+// Portion is from it for portions. Each is weighed by the inverse square of
+// half the range or of the portion, so that they compare with each other.
+func daySquares(products []product, variables []simplex.Var, objective string) []simplex.Square {
+
+	squares := []simplex.Square{}
+
+	switch objective {
 
-	pickedProducts := make([]product, n)
-	pickedIndexes := make([]int, n)
-	index := 0
+	case balanceObjective:
+		for _, t := range targets {
+			if math.IsInf(t.lower, -1) || math.IsInf(t.upper, 1) || t.lower == t.upper {
+				continue
+			}
+			expr := simplex.Expr{}
+			for i, p := range products {
+				expr[variables[i]] = t.amount(p)
+			}
+			half := (t.upper - t.lower) / 2.0
+			squares = append(squares, simplex.Square{Expr: expr, Target: t.lower + half, Weight: 1.0 / (half * half)})
+		}
 
-outer:
-	for index < n {
-		pickIndex := rand.Intn(len(products))
-		for _, i := range pickedIndexes[:index] {
-			if i == pickIndex {
-				continue outer
+	case portionsObjective:
+		for i, p := range products {
+			if p.Portion <= 0.0 {
+				continue
 			}
+			portion := p.Portion / 100.0
+			squares = append(squares, simplex.Square{Expr: simplex.Expr{variables[i]: 1.0}, Target: portion, Weight: 1.0 / (portion * portion)})
 		}
-		pickedProducts[index] = products[pickIndex]
-		pickedIndexes[index] = pickIndex
-		index++
 	}
 
-	return pickedProducts
+	return squares
 }
 
-func weekDay() int {
-	day := int(time.Now().Weekday())
-	if day == 0 {
-		day = 6
-	} else {
-		day--
-	}
-	return day
+// isQuadratic reports whether objective is a sum of squares to minimize.
+func isQuadratic(objective string) bool {
+	return objective == balanceObjective || objective == portionsObjective
 }
 
-func writeJSON(structure interface{}, path string) error {
-
-	data, e := json.MarshalIndent(structure, "", "    ")
-	if e != nil {
-		return e
+// stages returns the stages of a lexicographic solve of objectives in order,
+// exprs[k] the expression of objectives[k] and squares[k] its squares. Cost
+// and violations are minimized, the others maximized. Quadratic objectives
+// come last and make one stage together that minimizes their squares.
+// Penalties of soft targets are the violations objective, or weigh into the
+// first stage when it is not listed.
+func stages(objectives []string, exprs []simplex.Expr, squares [][]simplex.Square) []simplex.Objective {
+
+	listed := false
+	for _, objective := range objectives {
+		listed = listed || objective == violationsObjective
 	}
 
-	e = ioutil.WriteFile(path, data, os.ModePerm)
-	if e != nil {
-		return e
-	}
+	s := []simplex.Objective{}
 
-	return nil
-}
+	for k, objective := range objectives {
 
-func productWithID(products []product, id uint64) (product, bool) {
+		if isQuadratic(objective) {
+			if len(s) == 0 || len(s[len(s)-1].Squares) == 0 {
+				s = append(s, simplex.Objective{
+					Expr:       simplex.Expr{},
+					Sense:      simplex.Minimization,
+					Tolerance:  objectiveTolerance,
+					Violations: k == 0 && !listed,
+				})
+			}
+			last := &s[len(s)-1]
+			last.Squares = append(last.Squares, squares[k]...)
+			continue
+		}
 
-	for _, p := range products {
-		if p.ID == id {
-			return p, true
+		sense := simplex.Maximization
+		if objective == costObjective || objective == violationsObjective {
+			sense = simplex.Minimization
 		}
+		s = append(s, simplex.Objective{
+			Expr:       exprs[k],
+			Sense:      sense,
+			Tolerance:  objectiveTolerance,
+			Violations: objective == violationsObjective || (k == 0 && !listed),
+		})
 	}
 
-	return product{}, false
+	return s
 }
 
-func setDietProducts(d diet, products []product) (diet, bool) {
+// parseObjectives splits a comma separated list of objectives and checks
+// that each is known and listed once, quadratic ones after the others and
+// not first.
+func parseObjectives(list string) ([]string, error) {
 
-	for _, day := range d {
-		for i, entry := range day {
-			p, ok := productWithID(products, entry.ID)
-			if !ok {
-				fmt.Println(entry.ID)
-				return diet{}, false
+	objectives := strings.Split(list, ",")
+
+	for k, objective := range objectives {
+		objective = strings.TrimSpace(objective)
+		switch objective {
+		case macrosObjective, costObjective, violationsObjective, varietyObjective, balanceObjective, portionsObjective:
+		default:
+			return nil, fmt.Errorf("unknown objective %q", objective)
+		}
+		if k == 0 && isQuadratic(objective) {
+			return nil, fmt.Errorf("objective %q needs another before it to pick products", objective)
+		}
+		if k > 0 && isQuadratic(objectives[k-1]) && !isQuadratic(objective) {
+			return nil, fmt.Errorf("objective %q follows %q", objective, objectives[k-1])
+		}
+		for _, before := range objectives[:k] {
+			if before == objective {
+				return nil, fmt.Errorf("objective %q listed twice", objective)
 			}
-			day[i].product = p
 		}
+		objectives[k] = objective
 	}
 
-	return d, true
+	return objectives, nil
 }
 
-func getDiet(path string, products []product) (diet, bool) {
+// selectProducts adds to a day with optional products a binary per product
+// telling whether it is eaten, linked to its amount by
+// `least*used <= amount <= Maximum*used`, where least is the greater of its
+// Minimum and leastAmount but no more than its Maximum, so that a product with
+// a Maximum under leastAmount can still be eaten. It requires exactly
+// productsPerDay products to be eaten and returns the binaries.
+func selectProducts(m *simplex.Model, prefix string, products []product, variables []simplex.Var, productsPerDay int) []simplex.Var {
 
-	if !isJSONPath(path) {
-		fmt.Println("Provided file is not a diet")
-		return diet{}, false
-	}
+	used := make([]simplex.Var, len(products))
+	perDay := simplex.Expr{}
 
-	d, e := readDiet(path)
-	if e != nil {
-		fmt.Println("Could not read diet")
-		return diet{}, false
-	}
+	for i, p := range products {
 
-	d, ok := setDietProducts(d, products)
-	if !ok {
-		fmt.Println("Could not find diet product")
-		return diet{}, false
+		name := prefix + p.name
+		used[i] = m.AddBinaryVar(name + " used")
+		least := math.Min(math.Max(p.Minimum, leastAmount), p.Maximum)
+
+		m.AddConstraint(name+" maximum", simplex.Expr{variables[i]: 1.0, used[i]: -p.Maximum / 100.0}, simplex.LE, 0.0)
+		m.AddConstraint(name+" minimum", simplex.Expr{variables[i]: 1.0, used[i]: -least / 100.0}, simplex.GE, 0.0)
+
+		perDay[used[i]] = 1.0
 	}
 
-	return d, true
-}
+	m.AddConstraint(prefix+"products", perDay, simplex.EQ, float64(productsPerDay))
 
-func main() {
+	return used
+}
 
-	rand.Seed(time.Now().UnixNano())
+// addWeeklyLimits adds to m the weekly targets over the amounts of given
+// days, the total of target k within lower[k] and upper[k], and the most grams
+// of every group in groups eaten over them.
+func addWeeklyLimits(m *simplex.Model, products []product, days [][]simplex.Var, lower []float64, upper []float64, groups map[string]float64) {
 
-	defaultFloat := float64(math.MaxFloat64)
-	defaultInteger := int64(math.MaxInt64)
+	for k, t := range weeklyTargets {
+		expr := simplex.Expr{}
+		for _, amounts := range days {
+			for i, p := range products {
+				expr[amounts[i]] = t.amount(p)
+			}
+		}
+		c := m.AddRange("weekly "+t.name, expr, lower[k], upper[k])
+		if t.penalty > 0.0 {
+			m.SetPenalty(c, t.penalty)
+		}
+	}
 
-	newProductFlag := flag.String("new-product", "", "Create new product")
-	newDietFlag := flag.String("new-diet", "", "Create optimized diet")
-	productsPerDayFlag := flag.Int64("products-per-day", defaultInteger, "Use with `-optimize` to set the number of products per day")
-	productsPerWeekFlag := flag.Int64("products-per-week", defaultInteger, "Use with `-optimize` to set the number of products per week")
-	dietFlag := flag.String("diet", "", "Diet actions. Show diet if alone")
-	productsFlag := flag.Bool("products", false, "Use with `-diet` flag to see products and amounts for the whole week")
-	remainingFlag := flag.Bool("remaining", false, "Use with `-diet` flag to see remaining products and amounts for today")
-	productFlag := flag.String("product", "", "Use with `-diet` and `-consumed` flags to add a product and consumed amount for today")
-	consumedFlag := flag.Float64("consumed", defaultFloat, "Use with `-diet` and `-product` flags to add a product and consumed amount for today")
-	resetConsumedFlag := flag.Bool("reset-consumed", false, "Use with `-diet` flag to reset all consumed amounts")
-	totalFlag := flag.Bool("total", false, "Use with `-diet` command to see total nutrients for today")
-	detailedFlag := flag.Bool("detailed", false, "Use with `-diet` and `-total` flags to see detailed total nutrients for today")
+	// Groups are added in order of their names, so that the same products
+	// always make the same model.
+	names := make([]string, 0, len(groups))
+	for group := range groups {
+		names = append(names, group)
+	}
+	sort.Strings(names)
+
+	for _, group := range names {
+		expr := simplex.Expr{}
+		for _, amounts := range days {
+			for i, p := range products {
+				if p.Group == group {
+					expr[amounts[i]] = 100.0
+				}
+			}
+		}
+		if len(expr) > 0 {
+			m.AddConstraint("weekly "+group, expr, simplex.LE, groups[group])
+		}
+	}
+}
 
-	flag.Parse()
+// week holds the variables of the week model by day and product.
+type week struct {
+	amounts [][]simplex.Var
+	pieces  [][]simplex.Var
+	used    [][]simplex.Var
+	eaten   []simplex.Var
+}
 
-	products := findProducts()
+// weekModel builds the mixed integer program of nDays days. Every day is a
+// day of dayModel with products made optional by selectProducts, and every
+// product gets a binary telling whether it is eaten in the week: on at least
+// minimumUses days if so, and on none otherwise. The week eats at most
+// productsPerWeek products, not exactly that many, and keeps to the weekly
+// targets and group limits. The objective of the model is the first, as in
+// dayModel. Every objective sums the objectives of the days, except variety,
+// which maximizes the number of products eaten in the week.
+func weekModel(products []product, nDays int, productsPerDay int, productsPerWeek int, minimumUses int, objectives []string) (*simplex.Model, week, []simplex.Objective) {
+
+	m := simplex.NewModel()
+
+	w := week{
+		amounts: make([][]simplex.Var, nDays),
+		pieces:  make([][]simplex.Var, nDays),
+		used:    make([][]simplex.Var, nDays),
+		eaten:   make([]simplex.Var, len(products)),
+	}
 
-	if len(*newProductFlag) > 0 {
+	perWeek := simplex.Expr{}
+	for i, p := range products {
+		w.eaten[i] = m.AddBinaryVar(p.name + " eaten in week")
+		perWeek[w.eaten[i]] = 1.0
+	}
+	m.AddConstraint("products per week", perWeek, simplex.LE, float64(productsPerWeek))
 
-		path := setJSONExtension(filepath.Clean(*newProductFlag))
+	uses := make([]simplex.Expr, len(products))
+	for i := range products {
+		uses[i] = simplex.Expr{w.eaten[i]: -float64(minimumUses)}
+	}
 
-		p := product{
-			ID: id(),
+	exprs := make([]simplex.Expr, len(objectives))
+	squares := make([][]simplex.Square, len(objectives))
+	for k, objective := range objectives {
+		exprs[k] = simplex.Expr{}
+		if objective == varietyObjective {
+			for _, eaten := range w.eaten {
+				exprs[k][eaten] = 1.0
+			}
 		}
+	}
 
-		e := writeJSON(p, path)
-		if e != nil {
-			fmt.Println("Could not create new product")
-			return
-		}
+	for d := 0; d < nDays; d++ {
 
-		return
+		prefix := fmt.Sprintf("day %d ", d+1)
 
-	} else if len(*newDietFlag) > 0 {
+		w.amounts[d], w.pieces[d], _ = addDay(m, prefix, products, true, true)
+		w.used[d] = selectProducts(m, prefix, products, w.amounts[d], productsPerDay)
 
-		if len(products) < 1 {
-			fmt.Println("No products found")
-			return
+		for k, objective := range objectives {
+			for v, c := range dayExpr(products, w.amounts[d], objective) {
+				exprs[k][v] = c
+			}
+			squares[k] = append(squares[k], daySquares(products, w.amounts[d], objective)...)
 		}
 
-		nWeekDays := 7
-
-		productsPerDay := 8
-		if *productsPerDayFlag != defaultInteger {
-			productsPerDay = int(*productsPerDayFlag)
+		for i, p := range products {
+			m.AddConstraint(prefix+p.name+" in week", simplex.Expr{w.used[d][i]: 1.0, w.eaten[i]: -1.0}, simplex.LE, 0.0)
+			uses[i][w.used[d][i]] = 1.0
 		}
+	}
 
-		productsPerWeek := 15
-		if *productsPerWeekFlag != defaultInteger {
-			productsPerWeek = int(*productsPerWeekFlag)
-		}
+	for i, p := range products {
+		m.AddConstraint(p.name+" uses", uses[i], simplex.GE, 0.0)
+	}
 
-		newDiet := make(diet, nWeekDays)
+	lower := make([]float64, len(weeklyTargets))
+	upper := make([]float64, len(weeklyTargets))
+	for k, t := range weeklyTargets {
+		lower[k], upper[k] = t.lower*float64(nDays), t.upper*float64(nDays)
+	}
 
-		weekIterations := 0
+	addWeeklyLimits(m, products, w.amounts, lower, upper, groupLimits)
 
-		var finished int32 = 0
+	goals := stages(objectives, exprs, squares)
+	m.SetObjective(goals[0].Expr, goals[0].Sense)
 
-		for i := 0; i < 8; i++ {
-			go func() {
-			newDietLoop:
-				for {
-					if atomic.LoadInt32(&finished) == 1 {
-						break
-					}
+	return m, w, goals
+}
 
-					thisNewDiet := make(diet, nWeekDays)
+// start returns the values of the variables of m that make plan d of the
+// week.
+func (w week) start(m *simplex.Model, products []product, d diet) []float64 {
 
-					weekProducts := pickRandomProducts(products, productsPerWeek)
+	index := map[uint64]int{}
+	for i, p := range products {
+		index[p.ID] = i
+	}
 
-					currentDay := 0
-					dayIterations := 0
-					for currentDay < nWeekDays {
-						if dayIterations > 7500 {
-							fmt.Printf("Iteration %d\n", weekIterations)
-							weekIterations++
-							continue newDietLoop
-						}
-						dayIterations++
+	values := make([]float64, m.NumVars())
 
-						dayProducts := pickRandomProducts(weekProducts, productsPerDay)
+	for day, entries := range d {
+		for _, entry := range entries {
+			i := index[entry.ID]
+			values[w.amounts[day][i]] = entry.Amount
+			values[w.used[day][i]] = 1.0
+			values[w.eaten[i]] = 1.0
+			if products[i].Unit > 0.0 {
+				values[w.pieces[day][i]] = math.Round(entry.Amount * 100.0 / products[i].Unit)
+			}
+		}
+	}
 
-						dayDiet, ok := dayDiet(dayProducts)
-						if !ok {
-							continue
-						}
+	return values
+}
 
-						thisNewDiet[currentDay] = dayDiet
-						currentDay++
-					}
+// dayLimits are what the days before leave to a day of the week: products
+// eaten already, how many products not eaten yet to take, products to eat
+// again to reach their uses, the share of every weekly target the day has to
+// keep to, and the grams left of every group. Without weekly set the day only
+// keeps to the daily targets.
+type dayLimits struct {
+	eaten    []bool
+	fresh    int
+	required []bool
+
+	weekly bool
+	lower  []float64
+	upper  []float64
+	groups map[string]float64
+}
 
-					atomic.StoreInt32(&finished, 1)
-					newDiet = thisNewDiet
-					break
-				}
-			}()
+// dayDiet picks exactly productsPerDay products for a day within limits and
+// returns the day with the status of the solve. The status is
+// simplex.Infeasible when no such choice meets the targets, and
+// simplex.LimitReached when the solve stopped early, with the best day found
+// so far if there is one. When dump is set, the model is written to that file
+// before it is solved, see writeModel.
+func dayDiet(ctx context.Context, products []product, productsPerDay int, limits dayLimits, objectives []string, timeLimit time.Duration, dump string) ([]dietEntry, simplex.Status, error) {
+
+	m, variables, _, goals := dayModel(products, objectives, true, true)
+	used := selectProducts(m, "", products, variables, productsPerDay)
+
+	fresh := simplex.Expr{}
+	for i, p := range products {
+		if !limits.eaten[i] {
+			fresh[used[i]] = 1.0
 		}
-
-		for atomic.LoadInt32(&finished) == 0 {
-			time.Sleep(1000 * time.Millisecond)
+		if limits.required[i] {
+			m.AddConstraint(p.name+" required", simplex.Expr{used[i]: 1.0}, simplex.EQ, 1.0)
 		}
+	}
+	m.AddConstraint("fresh products", fresh, simplex.EQ, float64(limits.fresh))
 
-		path := setJSONExtension(filepath.Clean(*newDietFlag))
+	if limits.weekly {
+		addWeeklyLimits(m, products, [][]simplex.Var{variables}, limits.lower, limits.upper, limits.groups)
+	}
 
-		e := writeJSON(newDiet, path)
-		if e != nil {
-			fmt.Println("Could not save diet")
-			return
+	if dump != "" {
+		if e := writeModel(m, dump); e != nil {
+			return []dietEntry{}, 0, e
 		}
+	}
 
-		return
-
-	} else if len(*dietFlag) > 0 && *productsFlag {
+	solution, e := simplex.SolveLexicographic(ctx, m, goals, simplex.Options{Method: method, TimeLimit: timeLimit, GapTolerance: gapTolerance})
+	if e != nil && !errors.Is(e, context.Canceled) {
+		return []dietEntry{}, solution.Status, e
+	}
+	if solution.Values == nil {
+		return []dietEntry{}, solution.Status, nil
+	}
 
-		*dietFlag = filepath.Clean(*dietFlag)
+	return dayEntries(products, solution, variables, used), solution.Status, nil
+}
 
-		diet, ok := getDiet(*dietFlag, products)
-		if !ok {
-			return
-		}
+// dayEntries returns the products eaten in a solution with their amounts.
+// Amounts of products left out are zero up to rounding errors.
+func dayEntries(products []product, solution *simplex.Solution, amounts []simplex.Var, used []simplex.Var) []dietEntry {
 
-		commonEntries := []dietEntry{}
+	entries := []dietEntry{}
 
-		for _, day := range diet {
-		dietProductsLoop:
-			for _, entry := range day {
-				for i, common := range commonEntries {
-					if common.product.name == entry.product.name {
-						commonEntries[i].Amount += entry.Amount
-						continue dietProductsLoop
-					}
-				}
-				commonEntries = append(commonEntries, entry)
-			}
+	for i, v := range amounts {
+		if solution.Value(used[i]) == 0.0 {
+			continue
 		}
+		entries = append(entries, dietEntry{
+			ID:      products[i].ID,
+			Amount:  solution.Value(v),
+			product: products[i],
+		})
+	}
 
-		for i, p := range commonEntries {
-			amount := p.Amount * 100.0
-			index := i + 1
-			fmt.Printf("%d) %s - %.0f\n", index, p.product.name, amount)
-		}
+	return entries
+}
 
-		return
+// dayByDay picks the days of a week one after another with dayDiet, for a
+// plan to start the search of weekDiet from. Every day adds its share of the
+// products not eaten yet, or fewer when the targets cannot be met with that
+// many, keeps to what the days before left of the weekly targets and group
+// limits, and eats products again on the days right after the first until
+// they reach minimumUses. It returns an empty diet when a day cannot be
+// picked. When dump is set, the model of every day is written to the file of
+// dumpPath, the last one tried for a day in place of the ones before.
+func dayByDay(ctx context.Context, products []product, nDays int, productsPerDay int, productsPerWeek int, minimumUses int, objectives []string, deadline time.Time, dump string) (diet, error) {
+
+	newDiet := make(diet, nDays)
+
+	limits := dayLimits{
+		eaten:    make([]bool, len(products)),
+		required: make([]bool, len(products)),
+		weekly:   true,
+		lower:    make([]float64, len(weeklyTargets)),
+		upper:    make([]float64, len(weeklyTargets)),
+		groups:   map[string]float64{},
+	}
+	for group, grams := range groupLimits {
+		limits.groups[group] = grams
+	}
 
-	} else if len(*dietFlag) > 0 && *remainingFlag {
+	uses := make([]int, len(products))
+	nEaten := 0
+	totals := make([]float64, len(weeklyTargets))
 
-		*dietFlag = filepath.Clean(*dietFlag)
+	for d := range newDiet {
 
-		diet, ok := getDiet(*dietFlag, products)
-		if !ok {
-			return
+		daysLeft := nDays - d
+
+		// Every day gets an even share of what is left of the weekly
+		// targets, so that early days do not leave the last ones nothing.
+		for k, t := range weeklyTargets {
+			limits.lower[k] = (t.lower*float64(nDays) - totals[k]) / float64(daysLeft)
+			limits.upper[k] = (t.upper*float64(nDays) - totals[k]) / float64(daysLeft)
 		}
 
-		today := weekDay()
+		for i := range products {
+			limits.required[i] = uses[i] > 0 && uses[i] < minimumUses
+		}
 
-		for i, entry := range diet[today] {
-			index := i + 1
-			amount := entry.Amount * 100.0
-			remaining := (entry.Amount - entry.Consumed) * 100.0
-			percentange := (amount - remaining) / amount * 100.0
-			fmt.Printf("%d) %s - %.0f%%, %.0f out of %.0f\n", index, entry.product.name, percentange, remaining, amount)
+		fresh := (productsPerWeek - nEaten + daysLeft - 1) / daysLeft
+		if fresh > productsPerDay || d == 0 {
+			fresh = productsPerDay
+		}
+		// Products first eaten now could not reach their uses.
+		if daysLeft < minimumUses {
+			fresh = 0
 		}
 
-		return
+		for limits.fresh = fresh; limits.fresh >= 0; limits.fresh-- {
 
-	} else if len(*dietFlag) > 0 && len(*productFlag) > 0 && *consumedFlag != defaultFloat {
+			timeLimit := time.Duration(0)
+			if !deadline.IsZero() {
+				timeLimit = time.Until(deadline)
+				if timeLimit <= 0 {
+					return diet{}, nil
+				}
+			}
 
-		*dietFlag = filepath.Clean(*dietFlag)
+			path := ""
+			if dump != "" {
+				path = dumpPath(dump, d+1)
+			}
 
-		diet, ok := getDiet(*dietFlag, products)
-		if !ok {
-			return
-		}
+			day, status, e := dayDiet(ctx, products, productsPerDay, limits, objectives, timeLimit, path)
+			if e != nil {
+				return diet{}, e
+			}
+			if status == simplex.Infeasible {
+				continue
+			}
+			if len(day) == 0 {
+				return diet{}, nil
+			}
 
-		today := weekDay()
+			newDiet[d] = day
+			break
+		}
 
-		for i, entry := range diet[today] {
-			if entry.product.name == *productFlag {
+		if newDiet[d] == nil {
+			return diet{}, nil
+		}
+
+		for _, entry := range newDiet[d] {
+			for i, p := range products {
+				if p.ID != entry.ID {
+					continue
+				}
+				if !limits.eaten[i] {
+					limits.eaten[i] = true
+					nEaten++
+				}
+				uses[i]++
+				for k, t := range weeklyTargets {
+					totals[k] += t.amount(p) * entry.Amount
+				}
+				if _, ok := limits.groups[p.Group]; ok {
+					limits.groups[p.Group] -= entry.Amount * 100.0
+				}
+			}
+		}
+	}
+
+	return newDiet, nil
+}
+
+// weekDiet solves the week model, starting from the plan of dayByDay when
+// there is one, and returns the diet of every day with the status of the
+// solve. The status is simplex.Infeasible when no choice of products of the
+// given numbers can meet the targets, and simplex.LimitReached when the
+// search stopped early, with the best diet found so far if there is one. When
+// dump is set, every model is written to a file before it is solved: the day
+// that shows whether any day can meet the targets as day 0, the days of
+// dayByDay by their numbers and the week model unnumbered, see dumpPath.
+func weekDiet(ctx context.Context, products []product, nDays int, productsPerDay int, productsPerWeek int, minimumUses int, objectives []string, timeLimit time.Duration, dump string) (diet, simplex.Status, error) {
+
+	var deadline time.Time
+	if timeLimit > 0 {
+		deadline = time.Now().Add(timeLimit)
+	}
+
+	first := ""
+	if dump != "" {
+		first = dumpPath(dump, 0)
+	}
+
+	// Every day of the week is a day of productsPerDay products that keeps to
+	// the daily targets, so when there is none there is no week either.
+	_, status, e := dayDiet(ctx, products, productsPerDay, dayLimits{
+		eaten:    make([]bool, len(products)),
+		fresh:    productsPerDay,
+		required: make([]bool, len(products)),
+	}, objectives, timeLimit, first)
+	if e != nil || status == simplex.Infeasible {
+		return diet{}, status, e
+	}
+
+	start, e := dayByDay(ctx, products, nDays, productsPerDay, productsPerWeek, minimumUses, objectives, deadline, dump)
+	if e != nil {
+		return diet{}, 0, e
+	}
+
+	m, w, goals := weekModel(products, nDays, productsPerDay, productsPerWeek, minimumUses, objectives)
+
+	if dump != "" {
+		if e := writeModel(m, weekPath(dump)); e != nil {
+			return diet{}, 0, e
+		}
+	}
+
+	options := simplex.Options{Method: method, GapTolerance: gapTolerance}
+	if len(start) > 0 {
+		options.Start = w.start(m, products, start)
+	}
+	if !deadline.IsZero() {
+		options.TimeLimit = time.Until(deadline)
+		if options.TimeLimit <= 0 {
+			options.TimeLimit = time.Nanosecond
+		}
+	}
+
+	solution, e := simplex.SolveLexicographic(ctx, m, goals, options)
+	if e != nil && !errors.Is(e, context.Canceled) {
+		return diet{}, solution.Status, e
+	}
+	if solution.Values == nil {
+		return diet{}, solution.Status, nil
+	}
+
+	newDiet := make(diet, nDays)
+
+	for d := range newDiet {
+		newDiet[d] = dayEntries(products, solution, w.amounts[d], w.used[d])
+		if !meetsTargets(newDiet[d]) {
+			return diet{}, solution.Status, fmt.Errorf("day %d misses its targets", d+1)
+		}
+	}
+
+	return newDiet, solution.Status, nil
+}
+
+// weekConflict finds why no week of nDays days keeps to the targets and
+// describes it. The targets of a day are first tried with every product at
+// once, any amount of it up to its Maximum as products not picked are not
+// eaten, so that the numbers of products only take part in the conflict when
+// they can be met that way. Then only the targets of a day are searched, with
+// products kept to their limits, and last only the weekly targets and limits,
+// with every day kept to its targets, as a search over every bound of a model
+// with integer variables would take long.
+func weekConflict(ctx context.Context, products []product, nDays int, productsPerDay int, productsPerWeek int, minimumUses int, timeLimit time.Duration) (string, error) {
+
+	var deadline time.Time
+	if timeLimit > 0 {
+		deadline = time.Now().Add(timeLimit)
+	}
+
+	options := func() simplex.Options {
+		if deadline.IsZero() {
+			return simplex.Options{Method: method}
+		}
+		left := time.Until(deadline)
+		if left <= 0 {
+			left = time.Nanosecond
+		}
+		return simplex.Options{Method: method, TimeLimit: left}
+	}
+
+	m, _, _, _ := dayModel(products, []string{macrosObjective}, true, true)
+	iis, e := simplex.IISContext(ctx, m, options())
+
+	if errors.Is(e, simplex.ErrNotInfeasible) {
+		var variables []simplex.Var
+		var constraints []simplex.Constraint
+		m, variables, constraints, _ = dayModel(products, []string{macrosObjective}, true, true)
+		selectProducts(m, "", products, variables, productsPerDay)
+		perDay, _ := m.ConstraintByName("products")
+		iis, e = simplex.ConstraintIISContext(ctx, m, options(), append(constraints, perDay))
+	}
+
+	if errors.Is(e, simplex.ErrNotInfeasible) {
+		m, _, _ = weekModel(products, nDays, productsPerDay, productsPerWeek, minimumUses, []string{macrosObjective})
+		weekly := []simplex.Constraint{}
+		for _, name := range weeklyNames() {
+			if c, ok := m.ConstraintByName(name); ok {
+				weekly = append(weekly, c)
+			}
+		}
+		iis, e = simplex.ConstraintIISContext(ctx, m, options(), weekly)
+	}
+
+	// An interrupted search still has a subset, which may not be irreducible.
+	if e != nil && (iis == nil || !errors.Is(e, context.Canceled)) {
+		return "", e
+	}
+
+	description := describeConflict(m, iis)
+	if !iis.Irreducible {
+		description += ", not all of which may be needed"
+	}
+
+	return description, nil
+}
+
+// weeklyNames returns the names of the constraints of the week model that
+// bound the week as a whole.
+func weeklyNames() []string {
+
+	names := []string{"products per week"}
+
+	for _, t := range weeklyTargets {
+		names = append(names, "weekly "+t.name)
+	}
+	for group := range groupLimits {
+		names = append(names, "weekly "+group)
+	}
+
+	return names
+}
+
+// describeConflict puts the bounds of iis of a day or week model into words:
+// targets with their bounds, product limits in grams, and the numbers of
+// products. When no bound is left, the targets of the days conflict with the
+// numbers of products and their uses alone.
+func describeConflict(m *simplex.Model, iis *simplex.IIS) string {
+
+	bounds := []string{}
+	whole := []string{}
+	counts := []string{}
+
+	constraint := func(c simplex.Constraint, upper bool) {
+
+		name := m.ConstraintName(c)
+		lower, bound := m.ConstraintBounds(c)
+		relation := "<="
+		if !upper {
+			relation, bound = ">=", lower
+		}
+
+		switch {
+		case name == "products":
+			counts = append(counts, fmt.Sprintf("%.0f products per day", bound))
+		case name == "products per week":
+			counts = append(counts, fmt.Sprintf("%.0f products per week", bound))
+		case strings.HasSuffix(name, " pieces"):
+			// Both sides of the equality may be in the subset.
+			product := strings.TrimSuffix(name, " pieces")
+			for _, w := range whole {
+				if w == product {
+					return
+				}
+			}
+			whole = append(whole, product)
+		default:
+			bounds = append(bounds, fmt.Sprintf("%s %s %.2f", name, relation, bound))
+		}
+	}
+
+	for _, c := range iis.LowerConstraints {
+		constraint(c, false)
+	}
+	for _, c := range iis.UpperConstraints {
+		constraint(c, true)
+	}
+
+	limits := func(vars []simplex.Var, upper bool) []string {
+		names := []string{}
+		for _, v := range vars {
+			lower, bound := m.VarBounds(v)
+			if !upper {
+				bound = lower
+			}
+			// No amount is below zero, which goes without saying.
+			if bound == 0.0 {
+				continue
+			}
+			names = append(names, fmt.Sprintf("%s (%.0f g)", m.VarName(v), bound*100.0))
+		}
+		return names
+	}
+
+	parts := bounds
+	if names := limits(iis.UpperVars, true); len(names) > 0 {
+		parts = append(parts, "the Maximum of "+productList(names))
+	}
+	if names := limits(iis.LowerVars, false); len(names) > 0 {
+		parts = append(parts, "the Minimum of "+productList(names))
+	}
+	if len(whole) > 0 {
+		parts = append(parts, "whole pieces of "+productList(whole))
+	}
+	parts = append(parts, counts...)
+
+	switch len(parts) {
+	case 0:
+		return "The daily targets conflict with the numbers of products and their uses"
+	case 1:
+		return parts[0] + " cannot be met"
+	}
+
+	return parts[0] + " conflicts with " + joinWords(parts[1:])
+}
+
+// productList names one product, or counts and names several.
+func productList(names []string) string {
+	if len(names) == 1 {
+		return names[0]
+	}
+	return fmt.Sprintf("these %d products: %s", len(names), strings.Join(names, ", "))
+}
+
+// joinWords joins words with commas and a last "and".
+func joinWords(words []string) string {
+	if len(words) == 1 {
+		return words[0]
+	}
+	return strings.Join(words[:len(words)-1], ", ") + " and " + words[len(words)-1]
+}
+
+// meetsTargets checks a day against the daily targets that are not soft.
+func meetsTargets(day []dietEntry) bool {
+
+	dl := 0.0001
+
+	for _, t := range targets {
+		if t.penalty > 0.0 {
+			continue
+		}
+		total := 0.0
+		for _, entry := range day {
+			total += t.amount(entry.product) * entry.Amount
+		}
+		if total > t.upper+dl || total < t.lower-dl {
+			return false
+		}
+	}
+
+	return true
+}
+
+// missedTargets describes the soft targets in ts that given days miss by
+// more than rounding together, with how much they miss them by. Bounds of the
+// targets are per day, so they are taken as many times as there are days.
+func missedTargets(days [][]dietEntry, ts []target) []string {
+
+	dl := 0.0001
+	n := float64(len(days))
+
+	missed := []string{}
+
+	for _, t := range ts {
+		if t.penalty == 0.0 {
+			continue
+		}
+		total := 0.0
+		for _, day := range days {
+			for _, entry := range day {
+				total += t.amount(entry.product) * entry.Amount
+			}
+		}
+		if total < t.lower*n-dl {
+			missed = append(missed, fmt.Sprintf("%s >= %.2f missed by %.2f", t.name, t.lower*n, t.lower*n-total))
+		} else if total > t.upper*n+dl {
+			missed = append(missed, fmt.Sprintf("%s <= %.2f missed by %.2f", t.name, t.upper*n, total-t.upper*n))
+		}
+	}
+
+	return missed
+}
+
+// method is the method that solves the linear programs of every solve.
+var method = simplex.SimplexMethod
+
+// setMethod sets the method named by name, `simplex` or `interior`.
+func setMethod(name string) error {
+
+	switch name {
+	case simplexMethod:
+		method = simplex.SimplexMethod
+	case interiorMethod:
+		method = simplex.InteriorPoint
+	default:
+		return fmt.Errorf("unknown method %q", name)
+	}
+
+	return nil
+}
+
+// setPenalties makes the targets named in soft, a comma separated list of
+// `name=penalty` pairs, soft at their penalties. A name with a "weekly "
+// prefix names a weekly target.
+func setPenalties(soft string) error {
+
+	for _, pair := range strings.Split(soft, ",") {
+
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("no penalty in %q", pair)
+		}
+
+		name := strings.TrimSpace(parts[0])
+		penalty, e := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if e != nil || penalty <= 0.0 || math.IsInf(penalty, 0) {
+			return fmt.Errorf("invalid penalty of %q", name)
+		}
+
+		ts := targets
+		if strings.HasPrefix(name, "weekly ") {
+			ts, name = weeklyTargets, strings.TrimPrefix(name, "weekly ")
+		}
+
+		found := false
+		for i := range ts {
+			if ts[i].name == name {
+				ts[i].penalty = penalty
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown target %q", name)
+		}
+	}
+
+	return nil
+}
+
+// isBinding reports whether value sits at a finite bound.
+func isBinding(value float64, bound float64) bool {
+	return !math.IsInf(bound, 0) && math.Abs(value-bound) <= 1e-6*(1.0+math.Abs(bound))
+}
+
+// explainDay solves day d of the diet again over its own products and prints
+// the targets and product limits that bind the optimum, with how much the
+// last of objectives improves per unit each of them is relaxed by. The other
+// days keep their amounts, so the day keeps to what they leave of the weekly
+// targets and group limits. Products counted in pieces keep their amounts, as
+// only a linear program has duals. Amounts that differ from the plan anyway,
+// as a tie or the tolerance of objectives allows, are printed with both
+// values.
+func explainDay(plan diet, d int, objectives []string) error {
+
+	day := plan[d]
+
+	// The products of the day are eaten, at least leastAmount of each as
+	// selectProducts has it.
+	products := make([]product, len(day))
+	for i, entry := range day {
+		products[i] = entry.product
+		products[i].Minimum = math.Min(math.Max(products[i].Minimum, leastAmount), products[i].Maximum)
+		if products[i].Unit > 0.0 {
+			products[i].Minimum = entry.Amount * 100.0
+			products[i].Maximum = entry.Amount * 100.0
+		}
+	}
+
+	m, variables, constraints, goals := dayModel(products, objectives, false, false)
+
+	lower := make([]float64, len(weeklyTargets))
+	upper := make([]float64, len(weeklyTargets))
+	for k, t := range weeklyTargets {
+		lower[k], upper[k] = t.lower*float64(len(plan)), t.upper*float64(len(plan))
+	}
+	groups := map[string]float64{}
+	for group, limit := range groupLimits {
+		groups[group] = limit
+	}
+
+	for other, entries := range plan {
+		if other == d {
+			continue
+		}
+		for _, entry := range entries {
+			for k, t := range weeklyTargets {
+				lower[k] -= t.amount(entry.product) * entry.Amount
+				upper[k] -= t.amount(entry.product) * entry.Amount
+			}
+			if _, ok := groups[entry.product.Group]; ok {
+				groups[entry.product.Group] -= entry.Amount * 100.0
+			}
+		}
+	}
+
+	addWeeklyLimits(m, products, [][]simplex.Var{variables}, lower, upper, groups)
+
+	// Ranges need an optimal basis.
+	solution, e := simplex.SolveLexicographic(context.Background(), m, goals, simplex.Options{Method: method, Crossover: true})
+	if e != nil {
+		return e
+	}
+	if solution.Status != simplex.Optimal {
+		fmt.Printf("Products of the day are %s\n", solution.Status)
+		return nil
+	}
+
+	fmt.Printf("Objective: %f\n", solution.Objective)
+
+	for i, entry := range day {
+		amount := solution.Value(variables[i]) * 100.0
+		if math.Abs(amount-entry.Amount*100.0) > 0.5 {
+			fmt.Printf("%s is %.1f grams here but %.1f grams in the plan\n", entry.product.name, amount, entry.Amount*100.0)
+		}
+	}
+
+	for i, t := range targets {
+		activity := solution.Activity(constraints[i])
+		improvement := math.Abs(solution.Dual(constraints[i]))
+		if isBinding(activity, t.upper) {
+			fmt.Printf("%s <= %.2f, relaxing by 1 improves objective by %f\n", t.name, t.upper, improvement)
+		} else if isBinding(activity, t.lower) {
+			fmt.Printf("%s >= %.2f, relaxing by 1 improves objective by %f\n", t.name, t.lower, improvement)
+		}
+	}
+
+	for k, t := range weeklyTargets {
+		c, _ := m.ConstraintByName("weekly " + t.name)
+		activity := solution.Activity(c)
+		improvement := math.Abs(solution.Dual(c))
+		if isBinding(activity, upper[k]) {
+			fmt.Printf("Weekly %s <= %.2f, of which the day may take %.2f, relaxing by 1 improves objective by %f\n", t.name, t.upper*float64(len(plan)), upper[k], improvement)
+		} else if isBinding(activity, lower[k]) {
+			fmt.Printf("Weekly %s >= %.2f, of which the day has to take %.2f, relaxing by 1 improves objective by %f\n", t.name, t.lower*float64(len(plan)), lower[k], improvement)
+		}
+	}
+
+	names := make([]string, 0, len(groups))
+	for group := range groups {
+		names = append(names, group)
+	}
+	sort.Strings(names)
+
+	for _, group := range names {
+		limit := groups[group]
+		c, ok := m.ConstraintByName("weekly " + group)
+		if !ok || !isBinding(solution.Activity(c), limit) {
+			continue
+		}
+		improvement := math.Abs(solution.Dual(c))
+		fmt.Printf("Weekly %s <= %.0f grams, of which the day may take %.0f, relaxing by 1 gram improves objective by %f\n", group, groupLimits[group], limit, improvement)
+	}
+
+	for i, p := range products {
+		if p.Unit > 0.0 {
+			continue
+		}
+		amount := solution.Value(variables[i]) * 100.0
+		improvement := math.Abs(solution.ReducedCost(variables[i])) / 100.0
+		if isBinding(amount, p.Maximum) {
+			fmt.Printf("%s Maximum %.0f, relaxing by 1 gram improves objective by %f\n", p.name, p.Maximum, improvement)
+		} else if isBinding(amount, p.Minimum) {
+			fmt.Printf("%s Minimum %.0f, relaxing by 1 gram improves objective by %f\n", p.name, p.Minimum, improvement)
+		}
+	}
+
+	// Squares leave no basis to range.
+	if solution.LowerRanges == nil {
+		return nil
+	}
+
+	fmt.Println("The plan stays the same while:")
+
+	for i, t := range targets {
+		if !math.IsInf(t.lower, -1) {
+			r := solution.LowerRange(constraints[i])
+			fmt.Printf("%s >= %.2f moves within %s\n", t.name, t.lower, formatRange(r))
+		}
+		if !math.IsInf(t.upper, 1) && t.upper != t.lower {
+			r := solution.UpperRange(constraints[i])
+			fmt.Printf("%s <= %.2f moves within %s\n", t.name, t.upper, formatRange(r))
+		}
+	}
+
+	// Only macros and cost have a coefficient per product.
+	coefficient := ""
+	switch objectives[len(objectives)-1] {
+	case macrosObjective:
+		coefficient = "macro score"
+	case costObjective:
+		coefficient = "price"
+	default:
+		return nil
+	}
+
+	for i, p := range products {
+		r := solution.ObjectiveRange(variables[i])
+		fmt.Printf("%s %s per 100 grams moves within %s\n", p.name, coefficient, formatRange(r))
+	}
+
+	return nil
+}
+
+// certifyDay checks in exact arithmetic that the amounts of a day keep to
+// the targets that are not soft and to the limits of its products. It
+// describes every bound they miss by more than certifyTolerance with how
+// much they miss it by, and separately every bound they miss only by
+// rounding with its exact distance. When they miss any by more, it also
+// tells whether any amounts of the same products meet every bound exactly.
+// Pieces are not counted, as an amount in grams is rarely a whole number of
+// pieces exactly.
+func certifyDay(day []dietEntry) ([]string, []string, error) {
+
+	products := make([]product, len(day))
+	for i, entry := range day {
+		products[i] = entry.product
+	}
+
+	m := simplex.NewModel()
+	variables, _, constraints := addDay(m, "", products, false, false)
+
+	values := make([]float64, m.NumVars())
+	for i, entry := range day {
+		values[variables[i]] = entry.Amount
+	}
+	exact := simplex.ExactValues(values)
+
+	vars, missedConstraints := m.Certify(exact)
+
+	missed := []string{}
+	rounding := []string{}
+
+	miss := func(bound float64, by float64, message string) {
+		if by <= certifyTolerance*math.Max(math.Abs(bound), 1.0) {
+			rounding = append(rounding, message)
+		} else {
+			missed = append(missed, message)
+		}
+	}
+
+	for _, v := range vars {
+		for i, p := range products {
+			if variables[i] != v {
+				continue
+			}
+			lower, upper := m.VarBounds(v)
+			if below, by := exactMiss(exact[v], lower, upper); below {
+				miss(lower, by, fmt.Sprintf("%s Minimum %.0f missed by %g grams", p.name, p.Minimum, by*100.0))
+			} else {
+				miss(upper, by, fmt.Sprintf("%s Maximum %.0f missed by %g grams", p.name, p.Maximum, by*100.0))
+			}
+		}
+	}
+
+	for _, c := range missedConstraints {
+		for i, t := range targets {
+			if constraints[i] != c {
+				continue
+			}
+			activity := new(big.Rat)
+			for j, p := range products {
+				activity.Add(activity, new(big.Rat).Mul(new(big.Rat).SetFloat64(t.amount(p)), exact[variables[j]]))
+			}
+			if below, by := exactMiss(activity, t.lower, t.upper); below {
+				miss(t.lower, by, fmt.Sprintf("%s >= %.2f missed by %g", t.name, t.lower, by))
+			} else {
+				miss(t.upper, by, fmt.Sprintf("%s <= %.2f missed by %g", t.name, t.upper, by))
+			}
+		}
+	}
+
+	if len(missed) == 0 {
+		return missed, rounding, nil
+	}
+
+	solution, e := simplex.SolveExact(context.Background(), m, simplex.Options{})
+	if e != nil {
+		return nil, nil, e
+	}
+	if solution.Status == simplex.Optimal {
+		missed = append(missed, "other amounts of the same products meet every bound exactly")
+	} else {
+		missed = append(missed, "no amounts of the same products meet every bound exactly")
+	}
+
+	return missed, rounding, nil
+}
+
+// exactMiss reports whether x is below lower rather than above upper, and
+// how far it is from the bound it misses.
+func exactMiss(x *big.Rat, lower float64, upper float64) (bool, float64) {
+
+	if !math.IsInf(lower, -1) {
+		by := new(big.Rat).Sub(new(big.Rat).SetFloat64(lower), x)
+		if by.Sign() > 0 {
+			f, _ := by.Float64()
+			return true, f
+		}
+	}
+
+	f, _ := new(big.Rat).Sub(x, new(big.Rat).SetFloat64(upper)).Float64()
+
+	return false, f
+}
+
+// writeModel writes m to the file at path, in MPS format when its extension
+// is ".mps" and in LP format otherwise.
+func writeModel(m *simplex.Model, path string) error {
+
+	file, e := os.Create(path)
+	if e != nil {
+		return e
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".mps") {
+		e = m.WriteMPS(file)
+	} else {
+		e = m.WriteLP(file)
+	}
+	if e != nil {
+		file.Close()
+		return e
+	}
+
+	return file.Close()
+}
+
+// dumpPath returns the file of given day for path, its name ending in the
+// number of the day in place of any number it ends in: "day1.lp" is
+// "day3.lp" for the third day.
+func dumpPath(path string, day int) string {
+	extension := filepath.Ext(path)
+	base := strings.TrimRight(strings.TrimSuffix(path, extension), "0123456789")
+	return fmt.Sprintf("%s%d%s", base, day, extension)
+}
+
+// weekPath returns the file of the week model for path, its name with no
+// number at the end: "day.lp" for "day1.lp".
+func weekPath(path string) string {
+	extension := filepath.Ext(path)
+	base := strings.TrimRight(strings.TrimSuffix(path, extension), "0123456789")
+	return base + extension
+}
+
+func formatRange(r simplex.Range) string {
+	return fmt.Sprintf("[%.2f, %.2f]", r.Lower, r.Upper)
+}
+
+func weekDay() int {
+	day := int(time.Now().Weekday())
+	if day == 0 {
+		day = 6
+	} else {
+		day--
+	}
+	return day
+}
+
+func writeJSON(structure interface{}, path string) error {
+
+	data, e := json.MarshalIndent(structure, "", "    ")
+	if e != nil {
+		return e
+	}
+
+	e = ioutil.WriteFile(path, data, os.ModePerm)
+	if e != nil {
+		return e
+	}
+
+	return nil
+}
+
+func productWithID(products []product, id uint64) (product, bool) {
+
+	for _, p := range products {
+		if p.ID == id {
+			return p, true
+		}
+	}
+
+	return product{}, false
+}
+
+func setDietProducts(d diet, products []product) (diet, bool) {
+
+	for _, day := range d {
+		for i, entry := range day {
+			p, ok := productWithID(products, entry.ID)
+			if !ok {
+				fmt.Println(entry.ID)
+				return diet{}, false
+			}
+			day[i].product = p
+		}
+	}
+
+	return d, true
+}
+
+func getDiet(path string, products []product) (diet, bool) {
+
+	if !isJSONPath(path) {
+		fmt.Println("Provided file is not a diet")
+		return diet{}, false
+	}
+
+	d, e := readDiet(path)
+	if e != nil {
+		fmt.Println("Could not read diet")
+		return diet{}, false
+	}
+
+	d, ok := setDietProducts(d, products)
+	if !ok {
+		fmt.Println("Could not find diet product")
+		return diet{}, false
+	}
+
+	return d, true
+}
+
+func main() {
+
+	rand.Seed(time.Now().UnixNano())
+
+	defaultFloat := float64(math.MaxFloat64)
+	defaultInteger := int64(math.MaxInt64)
+
+	newProductFlag := flag.String("new-product", "", "Create new product")
+	newDietFlag := flag.String("new-diet", "", "Create optimized diet")
+	productsPerDayFlag := flag.Int64("products-per-day", defaultInteger, "Use with `-optimize` to set the number of products per day")
+	productsPerWeekFlag := flag.Int64("products-per-week", defaultInteger, "Use with `-optimize` to set the most products per week")
+	objectiveFlag := flag.String("objective", macrosObjective, "Use with `-new-diet` or `-explain` to optimize comma separated `violations`, `macros`, `cost` and `variety` in order, then `balance` and `portions` together")
+	dietFlag := flag.String("diet", "", "Diet actions. Show diet if alone")
+	productsFlag := flag.Bool("products", false, "Use with `-diet` flag to see products and amounts for the whole week")
+	remainingFlag := flag.Bool("remaining", false, "Use with `-diet` flag to see remaining products and amounts for today")
+	productFlag := flag.String("product", "", "Use with `-diet` and `-consumed` flags to add a product and consumed amount for today")
+	consumedFlag := flag.Float64("consumed", defaultFloat, "Use with `-diet` and `-product` flags to add a product and consumed amount for today")
+	resetConsumedFlag := flag.Bool("reset-consumed", false, "Use with `-diet` flag to reset all consumed amounts")
+	totalFlag := flag.Bool("total", false, "Use with `-diet` command to see total nutrients for today")
+	detailedFlag := flag.Bool("detailed", false, "Use with `-diet` and `-total` flags to see detailed total nutrients for today")
+	explainFlag := flag.Bool("explain", false, "Use with `-diet` flag to see binding targets and product limits of each day")
+	usesFlag := flag.Int64("uses", 1, "Use with `-new-diet` to eat every product of the week on at least given number of days")
+	timeLimitFlag := flag.Float64("time-limit", 60.0, "Use with `-new-diet` to limit the search to given number of seconds, 0 for no limit")
+	dumpLPFlag := flag.String("dump-lp", "", "Use with `-new-diet` to write the problem of every day to given file numbered by day, the check that any day meets the targets numbered 0 and the problem of the week unnumbered, in MPS format when it ends with `.mps`")
+	softFlag := flag.String("soft", "", "Use with `-new-diet` or `-explain` to let comma separated `name=penalty` targets be missed at penalty per unit")
+	methodFlag := flag.String("method", simplexMethod, "Use with `-new-diet` or `-explain` to solve linear programs with the `simplex` or the `interior` point method")
+	certifyFlag := flag.Bool("certify", false, "Use with `-diet` flag to check in exact arithmetic that every day meets the targets and product limits")
+
+	flag.Parse()
+
+	if e := setMethod(*methodFlag); e != nil {
+		fmt.Println("Could not set method:", e)
+		return
+	}
+
+	if len(*softFlag) > 0 {
+		e := setPenalties(*softFlag)
+		if e != nil {
+			fmt.Println("Could not set soft targets:", e)
+			return
+		}
+	}
+
+	products := findProducts()
+
+	if len(*newProductFlag) > 0 {
+
+		path := setJSONExtension(filepath.Clean(*newProductFlag))
+
+		p := product{
+			ID: id(),
+		}
+
+		e := writeJSON(p, path)
+		if e != nil {
+			fmt.Println("Could not create new product")
+			return
+		}
+
+		return
+
+	} else if len(*newDietFlag) > 0 {
+
+		if len(products) < 1 {
+			fmt.Println("No products found")
+			return
+		}
+
+		objectives, e := parseObjectives(*objectiveFlag)
+		if e != nil {
+			fmt.Println("Could not read objectives:", e)
+			return
+		}
+
+		nWeekDays := 7
+
+		productsPerDay := 8
+		if *productsPerDayFlag != defaultInteger {
+			productsPerDay = int(*productsPerDayFlag)
+		}
+
+		productsPerWeek := 15
+		if *productsPerWeekFlag != defaultInteger {
+			productsPerWeek = int(*productsPerWeekFlag)
+		}
+
+		if productsPerDay > productsPerWeek || productsPerWeek > len(products) {
+			fmt.Println("Not enough products")
+			return
+		}
+
+		// Interrupting the search stops it like the time limit does.
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt)
+		defer signal.Stop(interrupt)
+		go func() {
+			<-interrupt
+			cancel()
+		}()
+
+		timeLimit := time.Duration(*timeLimitFlag * float64(time.Second))
+
+		newDiet, status, e := weekDiet(ctx, products, nWeekDays, productsPerDay, productsPerWeek, int(*usesFlag), objectives, timeLimit, *dumpLPFlag)
+		if e != nil {
+			fmt.Println("Could not solve diet:", e)
+			return
+		}
+		switch status {
+		case simplex.Infeasible:
+			fmt.Printf("No %d products per day and %d per week can meet the targets\n", productsPerDay, productsPerWeek)
+			conflict, e := weekConflict(ctx, products, nWeekDays, productsPerDay, productsPerWeek, int(*usesFlag), timeLimit)
+			if e != nil {
+				fmt.Println("Could not find the conflicting targets:", e)
+				return
+			}
+			fmt.Println(conflict)
+			return
+		}
+
+		missed := []string{}
+		for i, day := range newDiet {
+			for _, m := range missedTargets([][]dietEntry{day}, targets) {
+				missed = append(missed, fmt.Sprintf("Day %d: %s", i+1, m))
+			}
+		}
+		for _, m := range missedTargets(newDiet, weeklyTargets) {
+			missed = append(missed, "Weekly "+m)
+		}
+
+		switch {
+		case status == simplex.LimitReached && len(newDiet) == 0:
+			fmt.Println("Could not find a diet before the search stopped")
+			return
+		case status == simplex.LimitReached && len(missed) > 0:
+			fmt.Println("Search stopped, the diet may not be optimal")
+		case status == simplex.LimitReached:
+			fmt.Println("Search stopped, the diet meets the targets but may not be optimal")
+		case status != simplex.Optimal:
+			fmt.Println("Could not solve diet:", status)
+			return
+		}
+
+		for _, m := range missed {
+			fmt.Println(m)
+		}
+
+		path := setJSONExtension(filepath.Clean(*newDietFlag))
+
+		e = writeJSON(newDiet, path)
+		if e != nil {
+			fmt.Println("Could not save diet")
+			return
+		}
+
+		return
+
+	} else if len(*dietFlag) > 0 && *productsFlag {
+
+		*dietFlag = filepath.Clean(*dietFlag)
+
+		diet, ok := getDiet(*dietFlag, products)
+		if !ok {
+			return
+		}
+
+		commonEntries := []dietEntry{}
+
+		for _, day := range diet {
+		dietProductsLoop:
+			for _, entry := range day {
+				for i, common := range commonEntries {
+					if common.product.name == entry.product.name {
+						commonEntries[i].Amount += entry.Amount
+						continue dietProductsLoop
+					}
+				}
+				commonEntries = append(commonEntries, entry)
+			}
+		}
+
+		for i, p := range commonEntries {
+			amount := p.Amount * 100.0
+			index := i + 1
+			fmt.Printf("%d) %s - %.0f\n", index, p.product.name, amount)
+		}
+
+		return
+
+	} else if len(*dietFlag) > 0 && *remainingFlag {
+
+		*dietFlag = filepath.Clean(*dietFlag)
+
+		diet, ok := getDiet(*dietFlag, products)
+		if !ok {
+			return
+		}
+
+		today := weekDay()
+
+		for i, entry := range diet[today] {
+			index := i + 1
+			amount := entry.Amount * 100.0
+			remaining := (entry.Amount - entry.Consumed) * 100.0
+			percentange := (amount - remaining) / amount * 100.0
+			fmt.Printf("%d) %s - %.0f%%, %.0f out of %.0f\n", index, entry.product.name, percentange, remaining, amount)
+		}
+
+		return
+
+	} else if len(*dietFlag) > 0 && len(*productFlag) > 0 && *consumedFlag != defaultFloat {
+
+		*dietFlag = filepath.Clean(*dietFlag)
+
+		diet, ok := getDiet(*dietFlag, products)
+		if !ok {
+			return
+		}
+
+		today := weekDay()
+
+		for i, entry := range diet[today] {
+			if entry.product.name == *productFlag {
 
 				diet[today][i].Consumed += *consumedFlag / 100.0
 
@@ -957,6 +2110,66 @@ func main() {
 
 		return
 
+	} else if len(*dietFlag) > 0 && *explainFlag {
+
+		*dietFlag = filepath.Clean(*dietFlag)
+
+		diet, ok := getDiet(*dietFlag, products)
+		if !ok {
+			return
+		}
+
+		objectives, e := parseObjectives(*objectiveFlag)
+		if e != nil {
+			fmt.Println("Could not read objectives:", e)
+			return
+		}
+
+		for i := range diet {
+			fmt.Printf("Day %d:\n", i+1)
+			e := explainDay(diet, i, objectives)
+			if e != nil {
+				fmt.Println("Could not solve day diet:", e)
+				return
+			}
+			if i+1 < len(diet) {
+				fmt.Println()
+			}
+		}
+
+		return
+
+	} else if len(*dietFlag) > 0 && *certifyFlag {
+
+		*dietFlag = filepath.Clean(*dietFlag)
+
+		diet, ok := getDiet(*dietFlag, products)
+		if !ok {
+			return
+		}
+
+		for i, day := range diet {
+			missed, rounding, e := certifyDay(day)
+			if e != nil {
+				fmt.Println("Could not certify day diet:", e)
+				return
+			}
+			switch {
+			case len(missed) == 0 && len(rounding) == 0:
+				fmt.Printf("Day %d: meets every bound exactly\n", i+1)
+			case len(missed) == 0:
+				fmt.Printf("Day %d: meets every bound but for rounding\n", i+1)
+			}
+			for _, m := range missed {
+				fmt.Printf("Day %d: %s\n", i+1, m)
+			}
+			for _, m := range rounding {
+				fmt.Printf("Day %d: %s, only by rounding\n", i+1, m)
+			}
+		}
+
+		return
+
 	} else if len(*dietFlag) > 0 {
 
 		*dietFlag = filepath.Clean(*dietFlag)
@@ -0,0 +1,288 @@
+package simplex
+
+import "math"
+
+// perturbation is the relative size of the changes of costs that keep the dual
+// simplex method from stalling.
+const perturbation = 1e-6
+
+// start is a basis of a solver with the values of its columns, kept to solve
+// a problem again from it after its bounds change.
+type start struct {
+	solver *solver
+	basis  []int
+	x      []float64
+}
+
+// snapshot returns the current basis of the solver.
+func (t *solver) snapshot() *start {
+	return &start{
+		solver: t,
+		basis:  append([]int{}, t.basis...),
+		x:      append([]float64{}, t.x...),
+	}
+}
+
+// restore loads the basis s with structurals bounded by lower and upper.
+// Nonbasic structurals out of their new bounds are moved onto them.
+func (t *solver) restore(s *start, lower []float64, upper []float64) error {
+
+	copy(t.basis, s.basis)
+	copy(t.x, s.x)
+
+	for j := range t.basic {
+		t.basic[j] = false
+	}
+	for _, j := range t.basis {
+		t.basic[j] = true
+	}
+
+	for j := 0; j < t.nStructurals; j++ {
+		t.lower[j], t.upper[j] = lower[j], upper[j]
+		if !t.basic[j] {
+			t.x[j] = math.Min(math.Max(t.x[j], lower[j]), upper[j])
+		}
+	}
+
+	return t.refactor()
+}
+
+// reoptimize solves the problem again from the basis s under new bounds of
+// structurals. A change of bounds keeps the reduced costs, so the basis is
+// still dual feasible and the dual simplex method takes it back to primal
+// feasibility in a few pivots, after which the primal method cleans up. It
+// reports false when the basis cannot be used, and the problem has to be
+// solved from scratch.
+func (t *solver) reoptimize(s *start, lower []float64, upper []float64) (Status, bool) {
+
+	t.iterations = 0
+
+	if e := t.restore(s, lower, upper); e != nil {
+		return 0, false
+	}
+
+	t.price()
+	if !t.dualFeasible() {
+		return 0, false
+	}
+
+	cost := t.perturb()
+	status, e := t.dualOptimize()
+	t.cost = cost
+	if e != nil {
+		return 0, false
+	}
+	if status != Optimal {
+		return status, true
+	}
+
+	status, e = t.optimize(t.firstArtificial)
+	if e != nil || status == Unbounded {
+		return 0, false
+	}
+
+	return status, true
+}
+
+// perturb moves the costs of nonbasic columns away from making them enter by
+// small amounts that differ between columns, so that reduced costs are not
+// tied at zero and dual pivots do not stall on them. It returns the costs from
+// before, which have to be restored after.
+func (t *solver) perturb() []float64 {
+
+	cost := t.cost
+	t.cost = append([]float64{}, cost...)
+
+	for j := 0; j < t.firstArtificial; j++ {
+
+		if t.basic[j] || t.lower[j] == t.upper[j] {
+			continue
+		}
+
+		// Multiples of the golden ratio spread shifts of neighbouring
+		// columns evenly.
+		shift := perturbation * (1.0 + math.Abs(cost[j])) * (1.0 + math.Mod(float64(j)*0.618033988749895, 1.0))
+
+		if t.x[j] <= t.lower[j] {
+			t.cost[j] += shift
+		} else if t.x[j] >= t.upper[j] {
+			t.cost[j] -= shift
+		}
+	}
+
+	return cost
+}
+
+// dualFeasible reports whether no nonbasic column can improve the objective,
+// that is whether the reduced costs have the signs of an optimum.
+func (t *solver) dualFeasible() bool {
+
+	for j := 0; j < t.firstArtificial; j++ {
+
+		if t.basic[j] || t.lower[j] == t.upper[j] {
+			continue
+		}
+
+		d := t.reduced[j]
+
+		if d < -t.optimalityTolerance && t.x[j] < t.upper[j] {
+			return false
+		}
+		if d > t.optimalityTolerance && t.x[j] > t.lower[j] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// dualOptimize pivots with the dual simplex method until all basic columns
+// are within their bounds. The basis has to be dual feasible. Like the primal
+// method it falls back to Bland's rule after a run of degenerate pivots.
+func (t *solver) dualOptimize() (Status, error) {
+
+	t.degenerate = 0
+
+	for {
+		if t.factor.stale() {
+			if e := t.refactor(); e != nil {
+				return 0, e
+			}
+		}
+
+		t.price()
+
+		row, target := t.leaving()
+		if row == -1 {
+			t.refresh()
+			return Optimal, nil
+		}
+
+		q, d := t.dualRatio(row, t.x[t.basis[row]] < target)
+		if q == -1 {
+			return Infeasible, nil
+		}
+		if t.stop(t.iterations) {
+			return LimitReached, nil
+		}
+
+		alpha := t.column(q)
+		if math.Abs(alpha[row]) <= t.pivotTolerance {
+			return 0, ErrNumerical
+		}
+
+		leaving := t.basis[row]
+
+		// Column q moves as far as it takes to put the leaving column on
+		// the bound it violates.
+		step := (t.x[leaving] - target) / alpha[row]
+		direction := 1.0
+		if step < 0.0 {
+			direction, step = -1.0, -step
+		}
+
+		// The objective changes in proportion to the reduced cost of q, a
+		// pivot on a zero reduced cost is degenerate for the dual.
+		t.countDegenerate(d)
+
+		// A reduced cost on the wrong side of zero would carry its error
+		// into every other one through the pivot, so the cost of q is
+		// shifted to make it zero. The costs are restored after.
+		if d == 0.0 {
+			t.cost[q] -= t.reduced[q]
+		}
+
+		t.move(q, direction, step, alpha)
+		t.x[leaving] = target
+		t.pivot(row, q, alpha)
+
+		t.iterations++
+
+		if !isFinite(t.x[q]) {
+			return 0, ErrNumerical
+		}
+	}
+}
+
+// leaving returns the row whose basic column is farthest out of its bounds,
+// and the bound it violates. Under Bland's rule it is the lowest column out
+// of its bounds instead. The row is -1 when the basis is feasible.
+func (t *solver) leaving() (int, float64) {
+
+	bland := t.bland()
+	row, target, worst := -1, 0.0, t.feasibilityTolerance
+
+	for i, j := range t.basis {
+
+		d, bound := t.lower[j]-t.x[j], t.lower[j]
+		if d < t.x[j]-t.upper[j] {
+			d, bound = t.x[j]-t.upper[j], t.upper[j]
+		}
+		if d <= t.feasibilityTolerance {
+			continue
+		}
+
+		if bland {
+			if row == -1 || j < t.basis[row] {
+				row, target = i, bound
+			}
+		} else if d > worst {
+			row, target, worst = i, bound, d
+		}
+	}
+
+	return row, target
+}
+
+// dualRatio returns the column that enters the basis when the column basic in
+// given row leaves it, going up to its lower bound when increase is true and
+// down to its upper bound otherwise, or -1 when no column can move it there
+// and the problem is infeasible. It is the column whose reduced cost reaches
+// zero first, ties going to the largest pivot, or under Bland's rule to the
+// lowest column. The reduced cost of the column is returned with it, zero when
+// rounding put it on the wrong side of zero.
+func (t *solver) dualRatio(row int, increase bool) (int, float64) {
+
+	rho := make([]float64, t.nRows)
+	rho[row] = 1.0
+	t.factor.btran(rho)
+
+	bland := t.bland()
+	q, best, pivot, reduced := -1, math.Inf(1), 0.0, 0.0
+
+	for j := 0; j < t.firstArtificial; j++ {
+
+		if t.basic[j] || t.lower[j] == t.upper[j] {
+			continue
+		}
+
+		a := t.dot(j, rho)
+		if math.Abs(a) <= t.pivotTolerance {
+			continue
+		}
+
+		// The basic column changes by -a for every unit column j moves, up
+		// from its lower bound or down from its upper bound. Reduced costs
+		// on the wrong side of zero by rounding count as zero.
+		d := 0.0
+		switch {
+		case t.x[j] < t.upper[j] && (a < 0.0) == increase:
+			d = math.Max(t.reduced[j], 0.0)
+		case t.x[j] > t.lower[j] && (a > 0.0) == increase:
+			d = math.Max(-t.reduced[j], 0.0)
+		default:
+			continue
+		}
+
+		r := d / math.Abs(a)
+
+		switch {
+		case r < best-t.optimalityTolerance:
+			q, best, pivot, reduced = j, r, math.Abs(a), d
+		case r <= best+t.optimalityTolerance && !bland && math.Abs(a) > pivot:
+			q, best, pivot, reduced = j, r, math.Abs(a), d
+		}
+	}
+
+	return q, reduced
+}
@@ -0,0 +1,152 @@
+package simplex
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// randomBounded returns a model of a few bounded variables and rows of small
+// integer coefficients, optimal or infeasible but never unbounded.
+func randomBounded(r *rand.Rand) *Model {
+
+	m := NewModel()
+
+	vars := make([]Var, 5)
+	for j := range vars {
+		lower := -float64(r.Intn(4))
+		vars[j] = m.AddVar(fmt.Sprint("x", j), lower, lower+float64(1+r.Intn(9)))
+	}
+
+	for i := 0; i < 4; i++ {
+		expr := Expr{}
+		for _, v := range vars {
+			if r.Intn(3) > 0 {
+				expr[v] = float64(r.Intn(11) - 5)
+			}
+		}
+		relation := []Relation{LE, GE, EQ}[r.Intn(3)]
+		m.AddConstraint(fmt.Sprint("c", i), expr, relation, float64(r.Intn(21)-10))
+	}
+
+	objective := Expr{}
+	for _, v := range vars {
+		objective[v] = float64(r.Intn(11) - 5)
+	}
+	m.SetObjective(objective, []Sense{Minimization, Maximization}[r.Intn(2)])
+
+	return m
+}
+
+// TestReoptimize branches on a variable at the optimum of a problem and
+// checks that the dual simplex method from the optimal basis ends where a
+// solve from scratch of the branch does.
+func TestReoptimize(t *testing.T) {
+
+	r := rand.New(rand.NewSource(1))
+	ctx := context.Background()
+	warm := 0
+
+	for k := 0; k < 300; k++ {
+
+		p := randomBounded(r).problem()
+
+		s, e := newSolver(p)
+		if e != nil {
+			t.Fatal(e)
+		}
+		s.configure(ctx, Options{})
+		status, feasible, e := s.twoPhase(p)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if status != Optimal || !feasible {
+			continue
+		}
+
+		// Cut off the value of a variable at the optimum, on either side.
+		j := r.Intn(len(p.lower))
+		branch := p
+		branch.lower = append([]float64{}, p.lower...)
+		branch.upper = append([]float64{}, p.upper...)
+		if r.Intn(2) == 0 {
+			branch.upper[j] = math.Ceil(s.x[j]) - 1.0
+		} else {
+			branch.lower[j] = math.Floor(s.x[j]) + 1.0
+		}
+		if branch.lower[j] > branch.upper[j] {
+			continue
+		}
+
+		want, e := solve(ctx, branch, Options{})
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		status, ok := s.reoptimize(s.snapshot(), branch.lower, branch.upper)
+		if !ok {
+			continue
+		}
+		warm++
+
+		if status != want.Status {
+			t.Errorf("problem %d: status %v, from scratch %v", k, status, want.Status)
+			continue
+		}
+		if status != Optimal {
+			continue
+		}
+
+		values, objective, e := s.point(branch)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if math.Abs(objective-want.Objective) > 1e-6*math.Max(1.0, math.Abs(want.Objective)) {
+			t.Errorf("problem %d: objective %g, from scratch %g", k, objective, want.Objective)
+		}
+		for j, x := range values {
+			if x < branch.lower[j]-1e-9 || x > branch.upper[j]+1e-9 {
+				t.Errorf("problem %d: x%d = %g out of [%g, %g]", k, j, x, branch.lower[j], branch.upper[j])
+			}
+		}
+	}
+
+	if warm < 50 {
+		t.Errorf("only %d branches reoptimized from their parent", warm)
+	}
+}
+
+func TestBranchAndBoundKnapsack(t *testing.T) {
+
+	m := NewModel()
+
+	values := []float64{10, 13, 7, 4, 9, 6}
+	weights := []float64{4, 6, 3, 2, 5, 3}
+
+	objective, weight := Expr{}, Expr{}
+	for j := range values {
+		v := m.AddBinaryVar(fmt.Sprint("item", j))
+		objective[v] = values[j]
+		weight[v] = weights[j]
+	}
+	m.AddConstraint("weight", weight, LE, 14)
+	m.Maximize(objective)
+
+	s, e := m.Solve()
+	if e != nil {
+		t.Fatal(e)
+	}
+	if s.Status != Optimal || math.Abs(s.Objective-30) > 1e-9 {
+		t.Fatalf("status %v, objective %g, want optimal 30", s.Status, s.Objective)
+	}
+	if s.Nodes < 2 {
+		t.Errorf("%d nodes, the relaxation is fractional", s.Nodes)
+	}
+	for j, x := range s.Values {
+		if x != 0 && x != 1 {
+			t.Errorf("item%d = %g", j, x)
+		}
+	}
+}
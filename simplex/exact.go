@@ -0,0 +1,510 @@
+package simplex
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// ExactSolution is the result of an exact solve. Objective, Values and Duals
+// are only meaningful when Status is Optimal. Values are indexed by Var and
+// Duals by Constraint, in the sense of the objective as in Result.
+type ExactSolution struct {
+	Status     Status
+	Objective  *big.Rat
+	Values     []*big.Rat
+	Duals      []*big.Rat
+	Iterations int
+
+	model *Model
+}
+
+// Value returns the value of v.
+func (s *ExactSolution) Value(v Var) *big.Rat {
+	if int(v) >= len(s.Values) {
+		return new(big.Rat)
+	}
+	return s.Values[v]
+}
+
+// Floats returns the values rounded to the nearest float64, indexed by Var.
+func (s *ExactSolution) Floats() []float64 {
+
+	values := make([]float64, len(s.Values))
+
+	for j, x := range s.Values {
+		values[j], _ = x.Float64()
+	}
+
+	return values
+}
+
+// SolveExact solves m by the simplex method over rational numbers, with no
+// rounding and no tolerances. Every coefficient and bound of the model is a
+// float64 and so a rational number, which the solve takes exactly, so the
+// solution is the exact optimum of the model as given. It is much slower than
+// Solve and suits small models: as an oracle to check other solves against,
+// and to settle whether a point meets every bound.
+//
+// Pivots follow Bland's rule, which never cycles in exact arithmetic.
+// MaxIterations and TimeLimit apply, the rest of options does not, but for
+// Start: a model with integer variables needs it, a solution whose values of
+// integer variables are kept while the rest is optimized.
+func SolveExact(ctx context.Context, m *Model, options Options) (*ExactSolution, error) {
+
+	solution := &ExactSolution{model: m}
+
+	if m.e != nil {
+		return solution, m.e
+	}
+	if e := options.check(); e != nil {
+		return solution, e
+	}
+
+	p := m.problem()
+
+	if p.integer != nil {
+		if len(options.Start) != len(m.variables) {
+			return solution, fmt.Errorf("%w: integer variables need a start", ErrOptions)
+		}
+		var e error
+		p, e = fixIntegers(p, options.Start)
+		if e != nil {
+			return solution, e
+		}
+	}
+
+	t := newExactSolver(p)
+	t.limits = newLimits(ctx, options)
+
+	solution.Status = t.twoPhase(p)
+	solution.Iterations = t.iterations
+
+	if solution.Status == LimitReached {
+		return solution, ctx.Err()
+	}
+	if solution.Status != Optimal {
+		return solution, nil
+	}
+
+	// The penalties of violated soft constraints are part of the objective,
+	// as their elastic columns are.
+	solution.Objective = new(big.Rat)
+	for j, c := range p.objective {
+		solution.Objective.Add(solution.Objective, new(big.Rat).Mul(rat(c), t.x[j]))
+	}
+	for j := 0; j < len(m.variables); j++ {
+		solution.Values = append(solution.Values, new(big.Rat).Set(t.x[j]))
+	}
+
+	solution.Duals = t.duals(p)
+
+	return solution, nil
+}
+
+// Certify reports, in exact arithmetic, the variables whose values are out of
+// their bounds or not integral when they have to be, and the hard constraints
+// whose left hand sides are out of their bounds. Values are indexed by Var,
+// and a point that meets every bound of m yields nothing. ExactValues turns a
+// solution of float64 values into one Certify takes.
+func (m *Model) Certify(values []*big.Rat) ([]Var, []Constraint) {
+
+	var vars []Var
+	var constraints []Constraint
+
+	value := func(v Var) *big.Rat {
+		if int(v) >= len(values) || values[v] == nil {
+			return new(big.Rat)
+		}
+		return values[v]
+	}
+
+	for j, v := range m.variables {
+		x := value(Var(j))
+		if !within(x, v.lower, v.upper) || (v.integer && !x.IsInt()) {
+			vars = append(vars, Var(j))
+		}
+	}
+
+	for c, r := range m.constraints {
+		if r.penalty != 0.0 {
+			continue
+		}
+		activity := new(big.Rat)
+		for v, x := range r.expr {
+			activity.Add(activity, new(big.Rat).Mul(rat(x), value(v)))
+		}
+		if !within(activity, r.lower, r.upper) {
+			constraints = append(constraints, Constraint(c))
+		}
+	}
+
+	return vars, constraints
+}
+
+// ExactValues returns values as the rational numbers they exactly are.
+func ExactValues(values []float64) []*big.Rat {
+
+	exact := make([]*big.Rat, len(values))
+
+	for j, x := range values {
+		exact[j] = rat(x)
+	}
+
+	return exact
+}
+
+// rat returns finite x as a rational number.
+func rat(x float64) *big.Rat {
+	return new(big.Rat).SetFloat64(x)
+}
+
+// within reports whether x lies between lower and upper, either of which may
+// be infinite.
+func within(x *big.Rat, lower float64, upper float64) bool {
+	if !math.IsInf(lower, -1) && x.Cmp(rat(lower)) < 0 {
+		return false
+	}
+	if !math.IsInf(upper, 1) && x.Cmp(rat(upper)) > 0 {
+		return false
+	}
+	return true
+}
+
+// exactSolver is a bounded-variable simplex method over rational numbers. It
+// lays out columns as solver does, structurals, logicals and artificials, but
+// keeps a dense tableau, the rows of the basis inverse times the matrix, as
+// exact arithmetic makes no factorization pay for its bookkeeping. An
+// infinite bound is nil.
+type exactSolver struct {
+	nRows           int
+	nStructurals    int
+	firstArtificial int
+	nColumns        int
+
+	tableau [][]*big.Rat
+	basis   []int
+	basic   []bool
+
+	x     []*big.Rat
+	lower []*big.Rat
+	upper []*big.Rat
+	cost  []*big.Rat
+
+	iterations int
+	limits
+}
+
+// newExactSolver starts a solver at the point and basis that newSolver starts
+// with, logicals and artificials placed the same way.
+func newExactSolver(p problem) *exactSolver {
+
+	nStructurals := len(p.objective)
+	nRows := len(p.rowLower)
+
+	t := &exactSolver{
+		nRows:           nRows,
+		nStructurals:    nStructurals,
+		firstArtificial: nStructurals + nRows,
+		basis:           make([]int, nRows),
+	}
+
+	bound := func(x float64) *big.Rat {
+		if math.IsInf(x, 0) {
+			return nil
+		}
+		return rat(x)
+	}
+
+	rows := make([][]*big.Rat, nRows)
+	for i := range rows {
+		rows[i] = make([]*big.Rat, nStructurals+nRows)
+		for j := range rows[i] {
+			rows[i][j] = new(big.Rat)
+		}
+	}
+
+	for j := 0; j < nStructurals; j++ {
+		t.lower = append(t.lower, bound(p.lower[j]))
+		t.upper = append(t.upper, bound(p.upper[j]))
+		t.x = append(t.x, rat(startingValue(p.lower[j], p.upper[j])))
+		indices, values := p.matrix.column(j)
+		for k, i := range indices {
+			rows[i][j].SetFloat64(values[k])
+		}
+	}
+
+	residuals := make([]*big.Rat, nRows)
+	for i := 0; i < nRows; i++ {
+		// The bounds of the logical are those of logicalBounds, the width of
+		// a ranged row taken without rounding.
+		rhs, lower, upper := logicalBounds(p.rowLower[i], p.rowUpper[i])
+		t.lower = append(t.lower, bound(lower))
+		t.upper = append(t.upper, bound(upper))
+		if !math.IsInf(p.rowUpper[i], 1) && !math.IsInf(p.rowLower[i], -1) {
+			t.upper[nStructurals+i] = new(big.Rat).Sub(rat(p.rowUpper[i]), rat(p.rowLower[i]))
+		}
+		t.x = append(t.x, new(big.Rat))
+		rows[i][nStructurals+i].SetInt64(1)
+
+		residuals[i] = rat(rhs)
+		for j := 0; j < nStructurals; j++ {
+			residuals[i].Sub(residuals[i], new(big.Rat).Mul(rows[i][j], t.x[j]))
+		}
+	}
+
+	var signs []int64
+	for i, r := range residuals {
+
+		logical := nStructurals + i
+
+		var sign int64
+		switch {
+		case t.lower[logical] != nil && r.Cmp(t.lower[logical]) < 0:
+			t.x[logical].Set(t.lower[logical])
+			sign = -1
+		case t.upper[logical] != nil && r.Cmp(t.upper[logical]) > 0:
+			t.x[logical].Set(t.upper[logical])
+			sign = 1
+		default:
+			t.x[logical].Set(r)
+			t.basis[i] = logical
+			signs = append(signs, 0)
+			continue
+		}
+
+		t.basis[i] = len(t.x)
+		t.lower = append(t.lower, new(big.Rat))
+		t.upper = append(t.upper, nil)
+		a := new(big.Rat).Sub(r, t.x[logical])
+		t.x = append(t.x, a.Mul(a, big.NewRat(sign, 1)))
+		signs = append(signs, sign)
+	}
+
+	t.nColumns = len(t.x)
+	t.basic = make([]bool, t.nColumns)
+	for _, j := range t.basis {
+		t.basic[j] = true
+	}
+
+	// The basis is diagonal, with a unit logical or a signed artificial in
+	// every row, so its inverse only flips the sign of artificial rows.
+	t.tableau = make([][]*big.Rat, nRows)
+	for i, row := range rows {
+		for j := t.firstArtificial; j < t.nColumns; j++ {
+			row = append(row, new(big.Rat))
+		}
+		if signs[i] != 0 {
+			row[t.basis[i]].SetInt64(signs[i])
+			if signs[i] < 0 {
+				for _, x := range row {
+					x.Neg(x)
+				}
+			}
+		}
+		t.tableau[i] = row
+	}
+
+	t.cost = make([]*big.Rat, t.nColumns)
+	for j := range t.cost {
+		t.cost[j] = new(big.Rat)
+	}
+
+	return t
+}
+
+// twoPhase runs both phases of the simplex method on p, as solver.twoPhase
+// does but for driving out artificials.
+func (t *exactSolver) twoPhase(p problem) Status {
+
+	for j := t.firstArtificial; j < t.nColumns; j++ {
+		t.cost[j].SetInt64(1)
+	}
+
+	status := t.optimize()
+	if status != Optimal {
+		return status
+	}
+	for j := t.firstArtificial; j < t.nColumns; j++ {
+		if t.x[j].Sign() != 0 {
+			return Infeasible
+		}
+	}
+
+	// Artificials left in the basis are at zero, and stay there once fixed.
+	for j := t.firstArtificial; j < t.nColumns; j++ {
+		t.cost[j].SetInt64(0)
+		t.upper[j] = new(big.Rat)
+	}
+	for j, c := range p.objective {
+		t.cost[j].SetFloat64(c)
+		if p.sense == Maximization {
+			t.cost[j].Neg(t.cost[j])
+		}
+	}
+
+	return t.optimize()
+}
+
+// reduced returns the reduced costs `c - c_B·T` of all columns.
+func (t *exactSolver) reduced() []*big.Rat {
+
+	reduced := make([]*big.Rat, t.nColumns)
+
+	for j := range reduced {
+		reduced[j] = new(big.Rat).Set(t.cost[j])
+	}
+	for i, row := range t.tableau {
+		c := t.cost[t.basis[i]]
+		if c.Sign() == 0 {
+			continue
+		}
+		for j, x := range row {
+			if x.Sign() != 0 {
+				reduced[j].Sub(reduced[j], new(big.Rat).Mul(c, x))
+			}
+		}
+	}
+
+	return reduced
+}
+
+// optimize pivots until no column improves the objective.
+func (t *exactSolver) optimize() Status {
+
+	for {
+
+		if t.stop(t.iterations) {
+			return LimitReached
+		}
+
+		reduced := t.reduced()
+
+		// Bland's rule enters the first column that improves the objective.
+		q, direction := -1, 0
+		for j, d := range reduced {
+			if t.basic[j] {
+				continue
+			}
+			if d.Sign() < 0 && (t.upper[j] == nil || t.x[j].Cmp(t.upper[j]) < 0) {
+				q, direction = j, 1
+				break
+			}
+			if d.Sign() > 0 && (t.lower[j] == nil || t.x[j].Cmp(t.lower[j]) > 0) {
+				q, direction = j, -1
+				break
+			}
+		}
+		if q == -1 {
+			return Optimal
+		}
+
+		// The entering column moves until it reaches its other bound or a
+		// basic column reaches one of its own, the first of those to leave on
+		// a tie.
+		var step *big.Rat
+		leaving := -1
+
+		if direction > 0 && t.upper[q] != nil {
+			step = new(big.Rat).Sub(t.upper[q], t.x[q])
+		} else if direction < 0 && t.lower[q] != nil {
+			step = new(big.Rat).Sub(t.x[q], t.lower[q])
+		}
+
+		for i, row := range t.tableau {
+
+			a := row[q]
+			if a.Sign() == 0 {
+				continue
+			}
+
+			b := t.basis[i]
+			var limit *big.Rat
+			if a.Sign()*direction > 0 && t.lower[b] != nil {
+				limit = new(big.Rat).Sub(t.x[b], t.lower[b])
+			} else if a.Sign()*direction < 0 && t.upper[b] != nil {
+				limit = new(big.Rat).Sub(t.upper[b], t.x[b])
+			} else {
+				continue
+			}
+			limit.Quo(limit, new(big.Rat).Abs(a))
+
+			if step == nil || limit.Cmp(step) < 0 ||
+				(limit.Cmp(step) == 0 && leaving != -1 && b < t.basis[leaving]) {
+				step = limit
+				leaving = i
+			}
+		}
+
+		if step == nil {
+			return Unbounded
+		}
+
+		move := new(big.Rat).Set(step)
+		if direction < 0 {
+			move.Neg(move)
+		}
+		t.x[q].Add(t.x[q], move)
+		for i, row := range t.tableau {
+			if row[q].Sign() != 0 {
+				b := t.basis[i]
+				t.x[b].Sub(t.x[b], new(big.Rat).Mul(row[q], move))
+			}
+		}
+
+		if leaving != -1 {
+			t.pivot(leaving, q)
+		}
+
+		t.iterations++
+	}
+}
+
+// pivot makes column q basic in row r.
+func (t *exactSolver) pivot(r int, q int) {
+
+	pivot := t.tableau[r]
+	inverse := new(big.Rat).Inv(pivot[q])
+	for _, x := range pivot {
+		if x.Sign() != 0 {
+			x.Mul(x, inverse)
+		}
+	}
+
+	for i, row := range t.tableau {
+		if i == r || row[q].Sign() == 0 {
+			continue
+		}
+		factor := new(big.Rat).Set(row[q])
+		for j, x := range pivot {
+			if x.Sign() != 0 {
+				row[j].Sub(row[j], new(big.Rat).Mul(factor, x))
+			}
+		}
+	}
+
+	t.basic[t.basis[r]] = false
+	t.basic[q] = true
+	t.basis[r] = q
+}
+
+// duals returns the duals of rows, in the sense of the objective of p, as
+// solver.duals does.
+func (t *exactSolver) duals(p problem) []*big.Rat {
+
+	reduced := t.reduced()
+
+	duals := make([]*big.Rat, p.nConstraints)
+	for c := range duals {
+		duals[c] = new(big.Rat)
+	}
+	for i, c := range p.constraints {
+		duals[c].Set(reduced[t.nStructurals+i])
+		if p.sense == Minimization {
+			duals[c].Neg(duals[c])
+		}
+	}
+
+	return duals
+}
@@ -0,0 +1,406 @@
+package simplex
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// lp describes a model by dense rows, so that it can be built again with a
+// coefficient or a bound changed.
+type lp struct {
+	sense     Sense
+	objective []float64
+	lower     []float64
+	upper     []float64
+	integer   []bool
+
+	rows     [][]float64
+	rowLower []float64
+	rowUpper []float64
+}
+
+func (d lp) model() *Model {
+
+	m := NewModel()
+
+	vars := make([]Var, len(d.objective))
+	for j := range vars {
+		if d.integer != nil && d.integer[j] {
+			vars[j] = m.AddIntVar(fmt.Sprint("x", j), d.lower[j], d.upper[j])
+		} else {
+			vars[j] = m.AddVar(fmt.Sprint("x", j), d.lower[j], d.upper[j])
+		}
+	}
+
+	for i, row := range d.rows {
+		expr := Expr{}
+		for j, a := range row {
+			if a != 0.0 {
+				expr[vars[j]] = a
+			}
+		}
+		m.AddRange(fmt.Sprint("c", i), expr, d.rowLower[i], d.rowUpper[i])
+	}
+
+	objective := Expr{}
+	for j, c := range d.objective {
+		objective[vars[j]] = c
+	}
+	m.SetObjective(objective, d.sense)
+
+	return m
+}
+
+// copyLP returns a copy of d whose slices can be changed on their own.
+func copyLP(d lp) lp {
+	c := d
+	c.objective = append([]float64{}, d.objective...)
+	c.rowLower = append([]float64{}, d.rowLower...)
+	c.rowUpper = append([]float64{}, d.rowUpper...)
+	return c
+}
+
+// randomLP returns a problem of small integers with up to nVars variables and
+// nRows rows of every kind. Some are infeasible or unbounded.
+func randomLP(r *rand.Rand, nVars int, nRows int) lp {
+
+	inf := math.Inf(1)
+	n := 2 + r.Intn(nVars-1)
+	rows := 1 + r.Intn(nRows)
+
+	d := lp{sense: Minimization}
+	if r.Intn(2) == 0 {
+		d.sense = Maximization
+	}
+
+	for j := 0; j < n; j++ {
+		d.objective = append(d.objective, float64(r.Intn(11)-5))
+		lower, upper := 0.0, inf
+		switch r.Intn(5) {
+		case 0:
+			upper = float64(1 + r.Intn(8))
+		case 1:
+			lower = -float64(r.Intn(5))
+			upper = lower + float64(1+r.Intn(8))
+		case 2:
+			lower, upper = -inf, float64(r.Intn(10))
+		case 3:
+			lower = -inf
+		}
+		d.lower = append(d.lower, lower)
+		d.upper = append(d.upper, upper)
+	}
+
+	for i := 0; i < rows; i++ {
+		row := make([]float64, n)
+		for j := range row {
+			if r.Intn(3) > 0 {
+				row[j] = float64(r.Intn(13) - 6)
+			}
+		}
+		b := float64(r.Intn(31) - 5)
+		lower, upper := -inf, b
+		switch r.Intn(4) {
+		case 0:
+			lower, upper = -b, inf
+		case 1:
+			lower = b - float64(r.Intn(20))
+		case 2:
+			lower = b
+		}
+		d.rows = append(d.rows, row)
+		d.rowLower = append(d.rowLower, lower)
+		d.rowUpper = append(d.rowUpper, upper)
+	}
+
+	return d
+}
+
+// beale is the problem of Beale on which the simplex method with the rule of
+// Dantzig cycles without an anti-cycling rule.
+func beale() lp {
+	inf := math.Inf(1)
+	return lp{
+		sense:     Minimization,
+		objective: []float64{-0.75, 20, -0.5, 6},
+		lower:     []float64{0, 0, 0, 0},
+		upper:     []float64{inf, inf, inf, inf},
+		rows: [][]float64{
+			{0.25, -8, -1, 9},
+			{0.5, -12, -0.5, 3},
+			{0, 0, 1, 0},
+		},
+		rowLower: []float64{-inf, -inf, -inf},
+		rowUpper: []float64{0, 0, 1},
+	}
+}
+
+// exactObjective solves d exactly and returns its status and optimum.
+func exactObjective(t *testing.T, d lp) (Status, float64) {
+	t.Helper()
+	x, e := SolveExact(context.Background(), d.model(), Options{})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if x.Status != Optimal {
+		return x.Status, 0.0
+	}
+	objective, _ := x.Objective.Float64()
+	return x.Status, objective
+}
+
+// closeTo reports whether a and b agree to within tolerance, relative to
+// their size when that is larger than one.
+func closeTo(a float64, b float64, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance*math.Max(1.0, math.Max(math.Abs(a), math.Abs(b)))
+}
+
+// activities returns the left hand sides of the rows of d at values.
+func activities(d lp, values []float64) []float64 {
+	a := make([]float64, len(d.rows))
+	for i, row := range d.rows {
+		for j, x := range row {
+			a[i] += x * values[j]
+		}
+	}
+	return a
+}
+
+// checkPoint checks that r is feasible for d and has the objective it
+// reports, and that its duals and reduced costs prove it optimal: they price
+// every column, are nonzero only at bounds, with the sign that makes moving
+// off the bound worsen the objective, and their dual objective is the exact
+// optimum.
+func checkPoint(t *testing.T, d lp, r Result, optimum float64, tolerance float64) {
+	t.Helper()
+
+	at := func(x float64, bound float64) bool {
+		return math.Abs(x-bound) <= tolerance*math.Max(1.0, math.Abs(bound))
+	}
+
+	objective := 0.0
+	for j, x := range r.Values {
+		objective += d.objective[j] * x
+		if x < d.lower[j]-tolerance || x > d.upper[j]+tolerance {
+			t.Errorf("x%d = %g out of [%g, %g]", j, x, d.lower[j], d.upper[j])
+		}
+	}
+	if !closeTo(objective, r.Objective, tolerance) || !closeTo(r.Objective, optimum, tolerance) {
+		t.Errorf("objective %g at values %g, exact %g", r.Objective, objective, optimum)
+	}
+
+	sign := 1.0
+	if d.sense == Minimization {
+		sign = -1.0
+	}
+
+	// bound returns the bound that a nonzero dual y of x in [lower, upper]
+	// holds x at.
+	bound := func(name string, y float64, x float64, lower float64, upper float64) float64 {
+		switch {
+		case math.Abs(y) <= tolerance:
+			return 0.0
+		case sign*y > 0.0 && at(x, upper):
+			return upper
+		case sign*y < 0.0 && at(x, lower):
+			return lower
+		}
+		t.Errorf("%s = %g with dual %g, bounds [%g, %g]", name, x, y, lower, upper)
+		return 0.0
+	}
+
+	dual := 0.0
+	for i, a := range activities(d, r.Values) {
+		dual += r.Duals[i] * bound(fmt.Sprint("c", i), r.Duals[i], a, d.rowLower[i], d.rowUpper[i])
+	}
+	for j, x := range r.Values {
+		priced := d.objective[j]
+		for i, row := range d.rows {
+			priced -= r.Duals[i] * row[j]
+		}
+		if !closeTo(priced, r.ReducedCosts[j], tolerance) {
+			t.Errorf("reduced cost of x%d %g, priced %g", j, r.ReducedCosts[j], priced)
+		}
+		dual += r.ReducedCosts[j] * bound(fmt.Sprint("x", j), r.ReducedCosts[j], x, d.lower[j], d.upper[j])
+	}
+	if !closeTo(dual, optimum, tolerance) {
+		t.Errorf("dual objective %g, exact %g", dual, optimum)
+	}
+}
+
+// inside returns points strictly inside r on either side of x, as far as
+// halfway to its ends, or one away from x toward an infinite end.
+func inside(r Range, x float64) []float64 {
+	var points []float64
+	if r.Lower < x {
+		if math.IsInf(r.Lower, -1) {
+			points = append(points, x-1.0)
+		} else {
+			points = append(points, (r.Lower+x)/2.0)
+		}
+	}
+	if r.Upper > x {
+		if math.IsInf(r.Upper, 1) {
+			points = append(points, x+1.0)
+		} else {
+			points = append(points, (r.Upper+x)/2.0)
+		}
+	}
+	return points
+}
+
+// checkRanges checks the ranges of r against exact solves of d changed
+// within them: values stay optimal for any objective coefficient in its
+// range, and the optimum moves by the dual of a bound for any bound in its
+// range.
+func checkRanges(t *testing.T, d lp, r Result, tolerance float64) {
+	t.Helper()
+
+	for j, c := range d.objective {
+		for _, x := range inside(r.ObjectiveRanges[j], c) {
+			changed := copyLP(d)
+			changed.objective[j] = x
+			status, optimum := exactObjective(t, changed)
+			objective := 0.0
+			for k, v := range r.Values {
+				objective += changed.objective[k] * v
+			}
+			if status != Optimal || !closeTo(objective, optimum, tolerance) {
+				t.Errorf("objective of x%d at %g in %v: %v %g, values give %g", j, x, r.ObjectiveRanges[j], status, optimum, objective)
+			}
+		}
+	}
+
+	a := activities(d, r.Values)
+
+	for i := range d.rows {
+		equality := d.rowLower[i] == d.rowUpper[i]
+		for k, b := range []float64{d.rowLower[i], d.rowUpper[i]} {
+			if math.IsInf(b, 0) {
+				continue
+			}
+			ranges := r.LowerRanges
+			if k == 1 {
+				ranges = r.UpperRanges
+			}
+			for _, x := range inside(ranges[i], b) {
+				changed := copyLP(d)
+				if k == 0 || equality {
+					changed.rowLower[i] = x
+				}
+				if k == 1 || equality {
+					changed.rowUpper[i] = x
+				}
+				want := r.Objective
+				if math.Abs(a[i]-b) <= tolerance*math.Max(1.0, math.Abs(b)) {
+					want += r.Duals[i] * (x - b)
+				}
+				status, optimum := exactObjective(t, changed)
+				if status != Optimal || !closeTo(optimum, want, tolerance) {
+					t.Errorf("bound %d of c%d at %g in %v: %v %g, want %g", k, i, x, ranges[i], status, optimum, want)
+				}
+			}
+		}
+	}
+}
+
+// oracle solves d by every method and checks each against the exact solve.
+func oracle(t *testing.T, d lp) {
+	t.Helper()
+
+	status, optimum := exactObjective(t, d)
+	m := d.model()
+
+	methods := []struct {
+		name      string
+		options   Options
+		tolerance float64
+		ranges    bool
+	}{
+		{"dantzig", Options{Pricing: Dantzig}, 1e-7, true},
+		{"bland", Options{Pricing: Bland}, 1e-7, true},
+		{"steepest edge", Options{Pricing: SteepestEdge}, 1e-7, true},
+		{"interior point", Options{Method: InteriorPoint}, 1e-5, false},
+		{"crossover", Options{Method: InteriorPoint, Crossover: true}, 1e-7, true},
+	}
+
+	for _, method := range methods {
+		s, e := Solve(m, method.options)
+		if e != nil {
+			t.Errorf("%s: %v", method.name, e)
+			continue
+		}
+		if s.Status != status {
+			t.Errorf("%s: status %v, exact %v", method.name, s.Status, status)
+			continue
+		}
+		if status != Optimal {
+			continue
+		}
+		checkPoint(t, d, s.Result, optimum, method.tolerance)
+		if method.ranges {
+			checkRanges(t, d, s.Result, method.tolerance)
+		}
+	}
+}
+
+func TestOracleBeale(t *testing.T) {
+
+	d := beale()
+	status, optimum := exactObjective(t, d)
+	if status != Optimal || optimum != -1.25 {
+		t.Fatalf("exact %v %g, want optimal -1.25", status, optimum)
+	}
+
+	oracle(t, d)
+}
+
+func TestOracleInfeasible(t *testing.T) {
+	inf := math.Inf(1)
+	oracle(t, lp{
+		sense:     Maximization,
+		objective: []float64{1, 1},
+		lower:     []float64{0, 0},
+		upper:     []float64{inf, inf},
+		rows:      [][]float64{{1, 1}, {1, -1}, {0, 1}},
+		rowLower:  []float64{-inf, 3, 2},
+		rowUpper:  []float64{4, inf, inf},
+	})
+}
+
+func TestOracleUnbounded(t *testing.T) {
+	inf := math.Inf(1)
+	oracle(t, lp{
+		sense:     Minimization,
+		objective: []float64{-1, 2},
+		lower:     []float64{0, -inf},
+		upper:     []float64{inf, 5},
+		rows:      [][]float64{{1, -1}, {1, 1}},
+		rowLower:  []float64{-inf, 1},
+		rowUpper:  []float64{3, inf},
+	})
+}
+
+func TestOracleRandom(t *testing.T) {
+
+	r := rand.New(rand.NewSource(1))
+	counts := map[Status]int{}
+
+	for k := 0; k < 300; k++ {
+		d := randomLP(r, 6, 5)
+		status, _ := exactObjective(t, d)
+		counts[status]++
+		t.Run(fmt.Sprint(k), func(t *testing.T) {
+			oracle(t, d)
+		})
+	}
+
+	for _, status := range []Status{Optimal, Infeasible, Unbounded} {
+		if counts[status] == 0 {
+			t.Errorf("no %v problem among %v", status, counts)
+		}
+	}
+}
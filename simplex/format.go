@@ -0,0 +1,241 @@
+package simplex
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrFormat is returned when an MPS or LP file cannot be read.
+var ErrFormat = errors.New("simplex: malformed file")
+
+// formatError returns ErrFormat for given line of a file.
+func formatError(line int, format string, args ...interface{}) error {
+	return fmt.Errorf("%w: line %d: %s", ErrFormat, line, fmt.Sprintf(format, args...))
+}
+
+// lowered is a model as it is written to a file: the problem solved for its
+// objective, soft constraints with their elastic columns, and a name for
+// every column and row that the file formats accept.
+type lowered struct {
+	problem
+
+	columns []string
+	rows    []string
+}
+
+// lower returns the model as it is written to a file. Elastic columns are
+// named after their row, with a "_below" or "_above" suffix.
+func (m *Model) lower() lowered {
+
+	l := lowered{problem: m.problem()}
+
+	taken := map[string]bool{"obj": true}
+
+	for _, v := range m.variables {
+		l.columns = append(l.columns, fileName(v.name, taken))
+	}
+	for _, c := range l.constraints {
+		l.rows = append(l.rows, fileName(m.constraints[c].name, taken))
+	}
+
+	for i, c := range l.constraints {
+		r := m.constraints[c]
+		if r.penalty == 0.0 {
+			continue
+		}
+		if !math.IsInf(r.lower, -1) {
+			l.columns = append(l.columns, fileName(l.rows[i]+"_below", taken))
+		}
+		if !math.IsInf(r.upper, 1) {
+			l.columns = append(l.columns, fileName(l.rows[i]+"_above", taken))
+		}
+	}
+
+	return l
+}
+
+// rowEntries returns the columns and coefficients of every row.
+func (l lowered) rowEntries() ([][]int, [][]float64) {
+
+	columns := make([][]int, len(l.rows))
+	values := make([][]float64, len(l.rows))
+
+	for j := range l.columns {
+		rows, coefficients := l.matrix.column(j)
+		for k, i := range rows {
+			columns[i] = append(columns[i], j)
+			values[i] = append(values[i], coefficients[k])
+		}
+	}
+
+	return columns, values
+}
+
+// fileName returns name with characters the file formats do not allow in
+// names replaced by underscores, made different from the names taken so far.
+func fileName(name string, taken map[string]bool) string {
+
+	b := strings.Builder{}
+	for _, r := range name {
+		if r < 128 && (r == '_' || r == '.' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')) {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+
+	s := b.String()
+	if s == "" || (s[0] >= '0' && s[0] <= '9') || s[0] == '.' || isKeyword(s) {
+		s = "_" + s
+	}
+
+	unique := s
+	for k := 1; taken[unique]; k++ {
+		unique = fmt.Sprintf("%s_%d", s, k)
+	}
+	taken[unique] = true
+
+	return unique
+}
+
+// isKeyword reports whether name would be read as a word of the LP format.
+func isKeyword(name string) bool {
+	if _, ok := lpSections[strings.ToLower(name)]; ok {
+		return true
+	}
+	switch strings.ToLower(name) {
+	case "inf", "infinity", "free", "subject", "such":
+		return true
+	}
+	return false
+}
+
+// formatNumber writes x so that it is read back exactly.
+func formatNumber(x float64) string {
+	switch {
+	case math.IsInf(x, 1):
+		return "inf"
+	case math.IsInf(x, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(x, 'g', -1, 64)
+}
+
+// parseNumber reads a number of a file, infinity included.
+func parseNumber(s string) (float64, bool) {
+
+	switch strings.ToLower(s) {
+	case "inf", "+inf", "infinity", "+infinity":
+		return math.Inf(1), true
+	case "-inf", "-infinity":
+		return math.Inf(-1), true
+	}
+
+	x, e := strconv.ParseFloat(s, 64)
+	if e != nil || math.IsNaN(x) {
+		return 0.0, false
+	}
+
+	return x, true
+}
+
+// builder collects the columns and rows of a file in the order they appear
+// and turns them into a model.
+type builder struct {
+	sense     Sense
+	objective map[int]float64
+
+	columns     []string
+	columnIndex map[string]int
+	lower       []float64
+	upper       []float64
+	integer     []bool
+
+	rows     []string
+	rowIndex map[string]int
+	exprs    []map[int]float64
+	rowLower []float64
+	rowUpper []float64
+}
+
+func newBuilder() *builder {
+	return &builder{
+		sense:       Minimization,
+		objective:   map[int]float64{},
+		columnIndex: map[string]int{},
+		rowIndex:    map[string]int{},
+	}
+}
+
+// column returns the index of the column with given name, adding it with
+// default bounds `0 <= x <= +Inf` when it is new.
+func (b *builder) column(name string) int {
+
+	if j, ok := b.columnIndex[name]; ok {
+		return j
+	}
+
+	j := len(b.columns)
+	b.columnIndex[name] = j
+	b.columns = append(b.columns, name)
+	b.lower = append(b.lower, 0.0)
+	b.upper = append(b.upper, math.Inf(1))
+	b.integer = append(b.integer, false)
+
+	return j
+}
+
+// row adds a row with given name that bounds nothing yet. It reports false
+// when the name is taken.
+func (b *builder) row(name string) (int, bool) {
+
+	if _, ok := b.rowIndex[name]; ok {
+		return 0, false
+	}
+
+	i := len(b.rows)
+	b.rowIndex[name] = i
+	b.rows = append(b.rows, name)
+	b.exprs = append(b.exprs, map[int]float64{})
+	b.rowLower = append(b.rowLower, math.Inf(-1))
+	b.rowUpper = append(b.rowUpper, math.Inf(1))
+
+	return i, true
+}
+
+// model returns the model of the file. Its errors are those of the model.
+func (b *builder) model() (*Model, error) {
+
+	m := NewModel()
+
+	variables := make([]Var, len(b.columns))
+	for j, name := range b.columns {
+		variables[j] = m.AddVar(name, b.lower[j], b.upper[j])
+		if b.integer[j] {
+			m.variables[variables[j]].integer = true
+		}
+	}
+
+	for i, name := range b.rows {
+		expr := Expr{}
+		for j, x := range b.exprs[i] {
+			expr[variables[j]] = x
+		}
+		m.AddRange(name, expr, b.rowLower[i], b.rowUpper[i])
+	}
+
+	objective := Expr{}
+	for j, x := range b.objective {
+		objective[variables[j]] = x
+	}
+	m.SetObjective(objective, b.sense)
+
+	if m.e != nil {
+		return nil, m.e
+	}
+
+	return m, nil
+}
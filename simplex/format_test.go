@@ -0,0 +1,312 @@
+package simplex
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// formats are the ways a model is written to and read from a file.
+var formats = []struct {
+	name  string
+	write func(m *Model, b *bytes.Buffer) error
+	read  func(s string) (*Model, error)
+}{
+	{
+		name:  "LP",
+		write: func(m *Model, b *bytes.Buffer) error { return m.WriteLP(b) },
+		read:  func(s string) (*Model, error) { return ReadLP(strings.NewReader(s)) },
+	},
+	{
+		name:  "MPS",
+		write: func(m *Model, b *bytes.Buffer) error { return m.WriteMPS(b) },
+		read:  func(s string) (*Model, error) { return ReadMPS(strings.NewReader(s)) },
+	},
+}
+
+// roundTrip writes m in every format and returns the models read back.
+func roundTrip(t *testing.T, m *Model) map[string]*Model {
+	t.Helper()
+
+	read := map[string]*Model{}
+	for _, f := range formats {
+		b := &bytes.Buffer{}
+		if e := f.write(m, b); e != nil {
+			t.Fatalf("%s: %v", f.name, e)
+		}
+		r, e := f.read(b.String())
+		if e != nil {
+			t.Fatalf("%s: %v\n%s", f.name, e, b.String())
+		}
+		read[f.name] = r
+	}
+
+	return read
+}
+
+// named returns expr by the names of its variables in m, terms of zero left
+// out, as a file writes them for rows that have no other terms.
+func named(m *Model, expr Expr) map[string]float64 {
+	n := map[string]float64{}
+	for v, x := range expr {
+		if x != 0.0 {
+			n[m.VarName(v)] = x
+		}
+	}
+	return n
+}
+
+// compareModels reports every difference between the model read and the one
+// written. Variables are compared by name, as an LP file does not keep their
+// order.
+func compareModels(t *testing.T, format string, got *Model, want *Model) {
+	t.Helper()
+
+	if got.Sense() != want.Sense() {
+		t.Errorf("%s: sense %v, want %v", format, got.Sense(), want.Sense())
+	}
+	if got.NumVars() != want.NumVars() || got.NumConstraints() != want.NumConstraints() {
+		t.Fatalf("%s: %d variables and %d constraints, want %d and %d", format, got.NumVars(), got.NumConstraints(), want.NumVars(), want.NumConstraints())
+	}
+
+	for j := 0; j < want.NumVars(); j++ {
+		name := want.VarName(Var(j))
+		v, ok := got.VarByName(name)
+		if !ok {
+			t.Errorf("%s: no variable %s", format, name)
+			continue
+		}
+		lower, upper := got.VarBounds(v)
+		wantLower, wantUpper := want.VarBounds(Var(j))
+		if lower != wantLower || upper != wantUpper || got.IsInteger(v) != want.IsInteger(Var(j)) {
+			t.Errorf("%s: %s in [%g, %g] integer %v, want [%g, %g] integer %v", format,
+				name, lower, upper, got.IsInteger(v), wantLower, wantUpper, want.IsInteger(Var(j)))
+		}
+	}
+
+	for i := 0; i < want.NumConstraints(); i++ {
+		c := Constraint(i)
+		lower, upper := got.ConstraintBounds(c)
+		wantLower, wantUpper := want.ConstraintBounds(c)
+		if got.ConstraintName(c) != want.ConstraintName(c) || lower != wantLower || upper != wantUpper {
+			t.Errorf("%s: constraint %s in [%g, %g], want %s in [%g, %g]", format,
+				got.ConstraintName(c), lower, upper, want.ConstraintName(c), wantLower, wantUpper)
+		}
+		if g, w := named(got, got.constraints[i].expr), named(want, want.constraints[i].expr); !reflect.DeepEqual(g, w) {
+			t.Errorf("%s: constraint %s is %v, want %v", format, got.ConstraintName(c), g, w)
+		}
+	}
+
+	if g, w := named(got, got.objective), named(want, want.objective); !reflect.DeepEqual(g, w) {
+		t.Errorf("%s: objective %v, want %v", format, g, w)
+	}
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+
+	inf := math.Inf(1)
+
+	m := NewModel()
+	x := m.AddVar("x", 0, inf)
+	free := m.AddVar("free", -inf, inf)
+	below := m.AddVar("below", -inf, 4)
+	negative := m.AddVar("negative", -2.5, inf)
+	boxed := m.AddVar("boxed", 1e-3, 1e6)
+	fixed := m.AddVar("fixed", 3, 3)
+	count := m.AddIntVar("count", 0, 5)
+	many := m.AddIntVar("many", 2, inf)
+	shifted := m.AddIntVar("shifted", -3, -1)
+	choice := m.AddBinaryVar("choice")
+	m.AddVar("unused", 0, 1)
+
+	m.AddConstraint("le", Expr{x: 1, free: -2.25}, LE, 10)
+	m.AddConstraint("ge", Expr{below: 1, negative: 1}, GE, -inf)
+	m.AddConstraint("lower", Expr{below: 1, negative: 1}, GE, -7)
+	m.AddConstraint("eq", Expr{boxed: 0.1, fixed: 1, count: 1}, EQ, 0)
+	// -4 + (0.3 - -4) is not 0.3, so MPS needs a range of a neighbouring
+	// number for the upper bound to be read back exactly.
+	m.AddRange("range", Expr{many: 1, shifted: 2, choice: -1}, -4, 0.3)
+	m.AddRange("one sided", Expr{x: 1, choice: 1}, 1, inf)
+	m.Maximize(Expr{x: 1, free: 1e-9, count: -3, choice: 12345.678})
+
+	// A constraint that bounds nothing is left out of files, and names are
+	// changed where the formats do not allow them.
+	want := NewModel()
+	for j := 0; j < m.NumVars(); j++ {
+		lower, upper := m.VarBounds(Var(j))
+		name := m.VarName(Var(j))
+		if name == "free" {
+			name = "_free"
+		}
+		v := want.AddVar(name, lower, upper)
+		want.variables[v].integer = m.IsInteger(Var(j))
+	}
+	for i, r := range m.constraints {
+		if i == 1 {
+			continue
+		}
+		name := r.name
+		if name == "one sided" {
+			name = "one_sided"
+		}
+		want.AddRange(name, r.expr, r.lower, r.upper)
+	}
+	want.Maximize(m.objective)
+
+	for format, got := range roundTrip(t, m) {
+		compareModels(t, format, got, want)
+	}
+}
+
+// TestFormatRoundTripPenalties writes soft constraints with their elastic
+// columns, which are read back as variables priced at the penalties. The
+// model read has the optimum of the one written.
+func TestFormatRoundTripPenalties(t *testing.T) {
+
+	for _, sense := range []Sense{Minimization, Maximization} {
+
+		m := NewModel()
+		x := m.AddVar("x", 0, 10)
+		y := m.AddVar("y", 0, 10)
+		m.AddConstraint("most", Expr{x: 1, y: 1}, LE, 4)
+		least := m.AddRange("least", Expr{x: 1, y: 2}, 9, 12)
+		m.SetPenalty(least, 2.5)
+		m.SetObjective(Expr{x: 1, y: -1}, sense)
+
+		s, e := m.Solve()
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		for format, r := range roundTrip(t, m) {
+
+			if r.NumVars() != 4 || r.VarName(2) != "least_below" || r.VarName(3) != "least_above" {
+				t.Fatalf("%s %v: variables %v", format, sense, r.variables)
+			}
+			for _, v := range []Var{2, 3} {
+				if want := 2.5; (sense == Minimization && r.objective[v] != want) || (sense == Maximization && r.objective[v] != -want) {
+					t.Errorf("%s %v: %s priced %g", format, sense, r.VarName(v), r.objective[v])
+				}
+			}
+
+			got, e := r.Solve()
+			if e != nil {
+				t.Fatal(e)
+			}
+			if got.Status != Optimal || !near(got.Objective, s.Objective) || !nearAll(got.Values[:2], s.Values) {
+				t.Errorf("%s %v: %v %g at %v, written %v %g at %v", format, sense, got.Status, got.Objective, got.Values, s.Status, s.Objective, s.Values)
+			}
+		}
+	}
+}
+
+// TestFormatEmptyRow reads a row without columns, which both formats write
+// and read back.
+func TestFormatEmptyRow(t *testing.T) {
+
+	m, e := ReadMPS(strings.NewReader("ROWS\n G 0\nRHS\n    RHS 0 -1\nENDATA\n"))
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	want := NewModel()
+	want.AddConstraint("_0", Expr{}, GE, -1)
+	want.Minimize(Expr{})
+
+	for format, got := range roundTrip(t, m) {
+		compareModels(t, format, got, want)
+	}
+}
+
+// TestFormatNames writes names the formats do not allow.
+func TestFormatNames(t *testing.T) {
+
+	m := NewModel()
+	a := m.AddVar("a b", 0, 1)
+	b := m.AddVar("a_b", 0, 1)
+	c := m.AddVar("2x", 0, 1)
+	d := m.AddVar("end", 0, 1)
+	e := m.AddVar("x:y", 0, 1)
+	m.AddConstraint("obj", Expr{a: 1, b: 1, c: 1, d: 1, e: 1}, LE, 3)
+
+	for format, got := range roundTrip(t, m) {
+		names := []string{}
+		for j := 0; j < got.NumVars(); j++ {
+			names = append(names, got.VarName(Var(j)))
+		}
+		if want := "a_b a_b_1 _2x _end x_y"; strings.Join(names, " ") != want {
+			t.Errorf("%s: variables %v, want %s", format, names, want)
+		}
+		if got.ConstraintName(0) != "obj_1" {
+			t.Errorf("%s: constraint %s, want obj_1", format, got.ConstraintName(0))
+		}
+	}
+}
+
+func TestReadLPMalformed(t *testing.T) {
+
+	tests := map[string]string{
+		"no end":              "Minimize\n obj: x\nSubject To\n c: x >= 1\n",
+		"data after end":      "Minimize\n obj: x\nEnd\n x\n",
+		"unsupported section": "Minimize\n obj: x\nSOS\n s1: x:1\nEnd\n",
+		"second objective":    "Minimize\n obj: x\nMaximize\n obj: x\nEnd\n",
+		"outside a section":   "x + y\nMinimize\n obj: x\nEnd\n",
+		"no relation":         "Minimize\n obj: x\nSubject To\n c: x + y 3\nEnd\n",
+		"constant":            "Minimize\n obj: x + 3\nEnd\n",
+		"duplicate row":       "Minimize\n obj: x\nSubject To\n c: x >= 1\n c: x <= 2\nEnd\n",
+		"ranged relations":    "Minimize\n obj: x\nSubject To\n c: 1 <= x >= 2\nEnd\n",
+		"equal ranged row":    "Minimize\n obj: x\nSubject To\n c: 1 = x <= 2\nEnd\n",
+		"infinite term":       "Minimize\n obj: inf x\nEnd\n",
+		"no number":           "Minimize\n obj: x\nSubject To\n c: x >= y\nEnd\n",
+		"bound relation":      "Minimize\n obj: x\nBounds\n x 3\nEnd\n",
+		"bound column":        "Minimize\n obj: x\nBounds\n 0 <= 3\nEnd\n",
+		"crossed bounds":      "Minimize\n obj: x\nBounds\n 3 <= x <= 2\nEnd\n",
+		"unexpected token":    "Minimize\n obj: x y\nEnd\n",
+	}
+
+	for name, text := range tests {
+		if _, e := ReadLP(strings.NewReader(text)); e == nil {
+			t.Errorf("%s: no error", name)
+		} else if !errors.Is(e, ErrFormat) && !errors.Is(e, ErrBounds) {
+			t.Errorf("%s: error %v", name, e)
+		}
+	}
+}
+
+func TestReadMPSMalformed(t *testing.T) {
+
+	tests := map[string]string{
+		"no endata":          "NAME\nROWS\n N obj\n",
+		"data after endata":  "NAME\nROWS\n N obj\nENDATA\n x\n",
+		"unknown section":    "NAME\nROWZ\nENDATA\n",
+		"outside a section":  " N obj\nENDATA\n",
+		"unknown sense":      "OBJSENSE\n    UP\nENDATA\n",
+		"row fields":         "ROWS\n N\nENDATA\n",
+		"row type":           "ROWS\n N obj\n Q c\nENDATA\n",
+		"duplicate row":      "ROWS\n N obj\n G c\n L c\nENDATA\n",
+		"objective row":      "ROWS\n N obj\n G obj\nENDATA\n",
+		"marker":             "ROWS\n N obj\nCOLUMNS\n    M 'MARKER' 'INTSTART'\nENDATA\n",
+		"column fields":      "ROWS\n N obj\nCOLUMNS\n    x obj\nENDATA\n",
+		"column value":       "ROWS\n N obj\nCOLUMNS\n    x obj one\nENDATA\n",
+		"column row":         "ROWS\n N obj\nCOLUMNS\n    x c 1\nENDATA\n",
+		"rhs row":            "ROWS\n N obj\n G c\nRHS\n    RHS d 1\nENDATA\n",
+		"rhs value":          "ROWS\n N obj\n G c\nRHS\n    RHS c one\nENDATA\n",
+		"objective constant": "ROWS\n N obj\nRHS\n    RHS obj 1\nENDATA\n",
+		"empty rhs":          "ROWS\n N obj\nRHS\n    RHS\nENDATA\n",
+		"bound type":         "ROWS\n N obj\nBOUNDS\n XX BND x 1\nENDATA\n",
+		"bound value":        "ROWS\n N obj\nBOUNDS\n UP BND x\nENDATA\n",
+		"bound number":       "ROWS\n N obj\nBOUNDS\n UP BND x one\nENDATA\n",
+		"crossed bounds":     "ROWS\n N obj\nBOUNDS\n LO BND x 3\n UP BND x 2\nENDATA\n",
+	}
+
+	for name, text := range tests {
+		if _, e := ReadMPS(strings.NewReader(text)); e == nil {
+			t.Errorf("%s: no error", name)
+		} else if !errors.Is(e, ErrFormat) && !errors.Is(e, ErrBounds) {
+			t.Errorf("%s: error %v", name, e)
+		}
+	}
+}
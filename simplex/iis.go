@@ -0,0 +1,224 @@
+package simplex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ErrNotInfeasible is returned when a subset of conflicting bounds is asked of
+// a model that is feasible, or that a limit stopped the solver from showing
+// infeasible.
+var ErrNotInfeasible = errors.New("simplex: model is not infeasible")
+
+// IIS is an irreducible infeasible subset of a model: bounds of constraints
+// and variables that no point satisfies together, while dropping any one of
+// them lets the rest be satisfied. The other bounds of the model play no part
+// in the conflict. Integer variables keep their bounds and stay integral, as
+// without bounds branch-and-bound may never end, so only bounds of continuous
+// variables are part of the subset.
+//
+// Irreducible is false when a limit stopped the search before every bound was
+// tried. The bounds still conflict, but some of them may not be needed for
+// it.
+type IIS struct {
+	LowerConstraints []Constraint
+	UpperConstraints []Constraint
+	LowerVars        []Var
+	UpperVars        []Var
+
+	Irreducible bool
+}
+
+// member is a finite bound of a problem that may be part of the subset: the
+// lower or upper bound of a row, or of a column when row is false.
+type member struct {
+	row   bool
+	index int
+	upper bool
+}
+
+// bound returns the place of the bound m in p.
+func (m member) bound(p *problem) *float64 {
+	switch {
+	case m.row && m.upper:
+		return &p.rowUpper[m.index]
+	case m.row:
+		return &p.rowLower[m.index]
+	case m.upper:
+		return &p.upper[m.index]
+	}
+	return &p.lower[m.index]
+}
+
+// IIS finds an irreducible infeasible subset of the model with default
+// options.
+func (m *Model) IIS() (*IIS, error) {
+	return IISContext(context.Background(), m, Options{})
+}
+
+// IISContext finds an irreducible infeasible subset of m by a deletion
+// filter: every finite bound in turn is dropped, and stays dropped when the
+// model is still infeasible without it. Bounds of constraints are tried
+// before bounds of variables, so the subset keeps few constraints and the
+// variable bounds that make them conflict. That takes a solve per bound, which
+// for a model with integer variables is a search of its own. Options apply to
+// every solve, except TimeLimit, which limits the whole filter, and Start and
+// Incumbent, which are not used. When the limit or ctx stops the filter, the
+// bounds left untried are kept, and the error of ctx is returned along with
+// the subset if it was canceled.
+func IISContext(ctx context.Context, m *Model, options Options) (*IIS, error) {
+	return findIIS(ctx, m, options, nil)
+}
+
+// ConstraintIISContext is IISContext over the bounds of given constraints
+// only, while all other bounds of m hold throughout. The subset found is
+// irreducible among those constraints, and takes a solve per bound of them,
+// which keeps a search of a large model with integer variables short.
+func ConstraintIISContext(ctx context.Context, m *Model, options Options, constraints []Constraint) (*IIS, error) {
+	if constraints == nil {
+		constraints = []Constraint{}
+	}
+	return findIIS(ctx, m, options, constraints)
+}
+
+// findIIS runs the deletion filter over the bounds of given constraints, or
+// over every bound when constraints is nil.
+func findIIS(ctx context.Context, m *Model, options Options, constraints []Constraint) (*IIS, error) {
+
+	if m.e != nil {
+		return nil, m.e
+	}
+	if e := options.check(); e != nil {
+		return nil, e
+	}
+
+	var deadline time.Time
+	if options.TimeLimit > 0 {
+		deadline = time.Now().Add(options.TimeLimit)
+	}
+	options.Start, options.Incumbent = nil, nil
+
+	// Only feasibility matters, and with nothing to improve on
+	// branch-and-bound stops at its first integer solution.
+	p := m.problem()
+	p.objective = make([]float64, len(p.objective))
+
+	// infeasible reports whether p is shown infeasible with its bounds as
+	// they are now, and whether a limit kept the solver from telling.
+	infeasible := func() (bool, bool, error) {
+
+		solveOptions := options
+		if !deadline.IsZero() {
+			solveOptions.TimeLimit = time.Until(deadline)
+			if solveOptions.TimeLimit <= 0 {
+				return false, true, nil
+			}
+		}
+
+		r, e := solveProblem(ctx, p, solveOptions)
+		if e != nil && ctx.Err() == nil {
+			return false, false, e
+		}
+
+		return r.Status == Infeasible, r.Status == LimitReached, nil
+	}
+
+	shown, limited, e := infeasible()
+	if e != nil {
+		return nil, e
+	}
+	if !shown {
+		if limited && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, ErrNotInfeasible
+	}
+
+	candidate := make([]bool, len(m.constraints))
+	for _, c := range constraints {
+		if c < 0 || int(c) >= len(candidate) {
+			return nil, fmt.Errorf("%w: %d", ErrUnknownConstraint, c)
+		}
+		candidate[c] = true
+	}
+
+	// Bounds tried first are the likeliest to be dropped, so rows go before
+	// variables: a conflict is told best by the few constraints it needs and
+	// the bounds of variables that keep them apart. Elastic columns of soft
+	// constraints follow the variables of the model and are never part of
+	// the subset.
+	members := []member{}
+	for i := range p.rowLower {
+		if constraints != nil && !candidate[p.constraints[i]] {
+			continue
+		}
+		if !math.IsInf(p.rowLower[i], -1) {
+			members = append(members, member{true, i, false})
+		}
+		if !math.IsInf(p.rowUpper[i], 1) {
+			members = append(members, member{true, i, true})
+		}
+	}
+	for j := range m.variables {
+		if constraints != nil || (p.integer != nil && p.integer[j]) {
+			continue
+		}
+		if !math.IsInf(p.lower[j], -1) {
+			members = append(members, member{false, j, false})
+		}
+		if !math.IsInf(p.upper[j], 1) {
+			members = append(members, member{false, j, true})
+		}
+	}
+
+	iis := &IIS{Irreducible: true}
+	kept := []member{}
+
+	for k, b := range members {
+
+		if ctx.Err() != nil || (!deadline.IsZero() && !time.Now().Before(deadline)) {
+			iis.Irreducible = false
+			kept = append(kept, members[k:]...)
+			break
+		}
+
+		x := b.bound(&p)
+		value := *x
+		*x = math.Inf(1)
+		if !b.upper {
+			*x = math.Inf(-1)
+		}
+
+		shown, limited, e := infeasible()
+		if e != nil {
+			return nil, e
+		}
+		if shown {
+			continue
+		}
+
+		*x = value
+		kept = append(kept, b)
+		if limited {
+			iis.Irreducible = false
+		}
+	}
+
+	for _, b := range kept {
+		switch {
+		case b.row && b.upper:
+			iis.UpperConstraints = append(iis.UpperConstraints, Constraint(p.constraints[b.index]))
+		case b.row:
+			iis.LowerConstraints = append(iis.LowerConstraints, Constraint(p.constraints[b.index]))
+		case b.upper:
+			iis.UpperVars = append(iis.UpperVars, Var(b.index))
+		default:
+			iis.LowerVars = append(iis.LowerVars, Var(b.index))
+		}
+	}
+
+	return iis, ctx.Err()
+}
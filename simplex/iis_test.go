@@ -0,0 +1,151 @@
+package simplex
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestIIS(t *testing.T) {
+
+	inf := math.Inf(1)
+
+	tests := []struct {
+		name  string
+		build func(m *Model)
+		// constraints limits the search to their bounds when not nil.
+		constraints []Constraint
+
+		want *IIS
+	}{
+		{
+			// y has to be negative for x + y <= 2 and x >= 3 to hold, so the
+			// lower bound of y is part of the conflict, the bound on z is not.
+			name: "rows and a variable",
+			build: func(m *Model) {
+				x := m.AddVar("x", 0, inf)
+				y := m.AddVar("y", 0, inf)
+				z := m.AddVar("z", 0, 5)
+				m.AddConstraint("sum", Expr{x: 1, y: 1}, LE, 2)
+				m.AddConstraint("least", Expr{x: 1}, GE, 3)
+				m.AddConstraint("loose", Expr{y: 1, z: 1}, LE, 10)
+			},
+			want: &IIS{
+				LowerConstraints: []Constraint{1},
+				UpperConstraints: []Constraint{0},
+				LowerVars:        []Var{1},
+				Irreducible:      true,
+			},
+		},
+		{
+			// x >= 2 conflicts both with x <= 1.5 and with the upper bound
+			// of x. Constraints are tried first, so the constraint goes and
+			// the bound stays.
+			name: "constraints before bounds",
+			build: func(m *Model) {
+				x := m.AddVar("x", 0, 1)
+				m.AddConstraint("least", Expr{x: 1}, GE, 2)
+				m.AddConstraint("most", Expr{x: 1}, LE, 1.5)
+			},
+			want: &IIS{
+				LowerConstraints: []Constraint{0},
+				UpperVars:        []Var{0},
+				Irreducible:      true,
+			},
+		},
+		{
+			name: "range",
+			build: func(m *Model) {
+				x := m.AddVar("x", -inf, inf)
+				y := m.AddVar("y", -inf, inf)
+				m.AddRange("between", Expr{x: 1, y: -1}, 1, 2)
+				m.AddConstraint("equal", Expr{x: 1, y: -1}, EQ, 0)
+			},
+			want: &IIS{
+				LowerConstraints: []Constraint{0},
+				UpperConstraints: []Constraint{1},
+				Irreducible:      true,
+			},
+		},
+		{
+			// Variable bounds hold throughout a search over constraints.
+			name: "given constraints",
+			build: func(m *Model) {
+				x := m.AddVar("x", 0, 1)
+				m.AddConstraint("least", Expr{x: 1}, GE, 2)
+				m.AddConstraint("most", Expr{x: 1}, LE, 1.5)
+			},
+			constraints: []Constraint{0, 1},
+			want: &IIS{
+				LowerConstraints: []Constraint{0},
+				Irreducible:      true,
+			},
+		},
+		{
+			// 2x = 3 has no integer solution, while either of its bounds
+			// alone has. Integer variables keep their bounds.
+			name: "integer",
+			build: func(m *Model) {
+				x := m.AddIntVar("x", 0, 10)
+				y := m.AddVar("y", 0, 10)
+				m.AddConstraint("half", Expr{x: 2}, EQ, 3)
+				m.AddConstraint("y", Expr{y: 1}, GE, 1)
+			},
+			want: &IIS{
+				LowerConstraints: []Constraint{0},
+				UpperConstraints: []Constraint{0},
+				Irreducible:      true,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			m := NewModel()
+			test.build(m)
+
+			var iis *IIS
+			var e error
+			if test.constraints != nil {
+				iis, e = ConstraintIISContext(context.Background(), m, Options{}, test.constraints)
+			} else {
+				iis, e = m.IIS()
+			}
+			if e != nil {
+				t.Fatal(e)
+			}
+			if !reflect.DeepEqual(iis, test.want) {
+				t.Errorf("got %+v, want %+v", iis, test.want)
+			}
+		})
+	}
+}
+
+func TestIISFeasible(t *testing.T) {
+
+	m := NewModel()
+	x := m.AddVar("x", 0, 1)
+	m.AddConstraint("least", Expr{x: 1}, GE, 0.5)
+
+	iis, e := m.IIS()
+	if !errors.Is(e, ErrNotInfeasible) {
+		t.Errorf("error %v, want %v", e, ErrNotInfeasible)
+	}
+	if iis != nil {
+		t.Errorf("subset %+v of a feasible model", iis)
+	}
+}
+
+func TestIISUnknownConstraint(t *testing.T) {
+
+	m := NewModel()
+	x := m.AddVar("x", 0, 1)
+	m.AddConstraint("least", Expr{x: 1}, GE, 2)
+
+	if _, e := ConstraintIISContext(context.Background(), m, Options{}, []Constraint{1}); !errors.Is(e, ErrUnknownConstraint) {
+		t.Errorf("error %v, want %v", e, ErrUnknownConstraint)
+	}
+}
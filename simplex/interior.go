@@ -0,0 +1,780 @@
+package simplex
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+const (
+	// interiorTolerance is the relative infeasibility and duality gap at
+	// which the interior point method stops.
+	interiorTolerance = 1e-8
+	// interiorIterations limits the iterations of the interior point method
+	// unless MaxIterations limits them to fewer, as it converges in a few
+	// dozen when it converges at all.
+	interiorIterations = 200
+	// interiorDivergence is the size of iterates at which the interior point
+	// method gives up, as infeasible and unbounded problems drive them away.
+	interiorDivergence = 1e12
+	// stepScale is the fraction of the way to the boundary that a step of the
+	// interior point method goes.
+	stepScale = 0.995
+	// regularization keeps the normal equations positive definite when
+	// columns are free or rows dependent.
+	regularization = 1e-10
+	// snapTolerance is the relative distance to a bound within which
+	// crossover puts a value of the interior point on the bound.
+	snapTolerance = 1e-7
+)
+
+// Method chooses the algorithm that solves linear programs.
+type Method int
+
+const (
+	// SimplexMethod is the bounded-variable revised simplex method.
+	SimplexMethod Method = iota + 1
+	// InteriorPoint is the primal-dual interior point method of Mehrotra. Its
+	// iterations are few, however many variables there are, but each solves
+	// a dense system as large as the number of constraints.
+	InteriorPoint
+)
+
+func (m Method) String() string {
+	switch m {
+	case SimplexMethod:
+		return "simplex"
+	case InteriorPoint:
+		return "interior point"
+	}
+	return "unknown"
+}
+
+// interior is the interior point method on a problem in the form
+// `A·x - s = 0`, `lower <= (x, s) <= upper`, where the slack column s of a row
+// carries its bounds. Columns are laid out as structurals and slacks, and
+// columns with equal bounds stay fixed.
+//
+// Every finite bound has a dual: sl for lower bounds, su for upper ones. The
+// method follows the central path, where `(z - lower)·sl = (upper - z)·su = mu`
+// for every column z, as mu goes to zero. Each iteration solves the normal
+// equations `A·D·Aᵀ + Ds` twice, for the predictor and the corrector of
+// Mehrotra, with a dense Cholesky factorization.
+//
+// The objective may have a diagonal quadratic term, `cost·z + ½·zᵀ·Q·z` with
+// Q the non-negative quadratic, which only adds Q to D. It is nil for linear
+// programs.
+type interior struct {
+	matrix       sparseMatrix
+	nRows        int
+	nStructurals int
+
+	cost      []float64
+	quadratic []float64
+	lower     []float64
+	upper     []float64
+	fixed     []bool
+
+	z  []float64
+	y  []float64
+	sl []float64
+	su []float64
+
+	// Residuals and the diagonal of the current iteration.
+	rp   []float64
+	rd   []float64
+	dinv []float64
+
+	normal     [][]float64
+	iterations int
+	unbounded  bool
+}
+
+func newInterior(p problem) *interior {
+
+	nStructurals := len(p.objective)
+	nRows := len(p.rowLower)
+	n := nStructurals + nRows
+
+	ip := &interior{
+		matrix:       p.matrix,
+		nRows:        nRows,
+		nStructurals: nStructurals,
+		cost:         make([]float64, n),
+		lower:        append(append([]float64{}, p.lower...), p.rowLower...),
+		upper:        append(append([]float64{}, p.upper...), p.rowUpper...),
+		fixed:        make([]bool, n),
+		z:            make([]float64, n),
+		y:            make([]float64, nRows),
+		sl:           make([]float64, n),
+		su:           make([]float64, n),
+		rp:           make([]float64, nRows),
+		rd:           make([]float64, n),
+		dinv:         make([]float64, n),
+		normal:       make([][]float64, nRows),
+	}
+
+	for j, c := range p.objective {
+		if p.sense == Minimization {
+			ip.cost[j] = c
+		} else {
+			ip.cost[j] = -c
+		}
+	}
+
+	// Start within the bounds, a unit away from a single bound and halfway
+	// between two.
+	for j := range ip.z {
+		lower, upper := ip.lower[j], ip.upper[j]
+		switch {
+		case lower == upper:
+			ip.z[j] = lower
+			ip.fixed[j] = true
+			continue
+		case !math.IsInf(lower, -1) && !math.IsInf(upper, 1):
+			ip.z[j] = lower + 0.5*(upper-lower)
+		case !math.IsInf(lower, -1):
+			ip.z[j] = lower + 1.0
+		case !math.IsInf(upper, 1):
+			ip.z[j] = upper - 1.0
+		}
+		if !math.IsInf(lower, -1) {
+			ip.sl[j] = 1.0
+		}
+		if !math.IsInf(upper, 1) {
+			ip.su[j] = 1.0
+		}
+	}
+
+	// A slack rather starts at the activity of its row, kept a unit within
+	// its bounds, so that a row far from zero does not start far from
+	// feasible, which the method may never make up for.
+	activity := make([]float64, nRows)
+	for j := 0; j < nStructurals; j++ {
+		ip.scatter(j, ip.z[j], activity)
+	}
+	for i, a := range activity {
+		j := nStructurals + i
+		lower, upper := ip.lower[j], ip.upper[j]
+		if ip.fixed[j] || upper-lower < 2.0 {
+			continue
+		}
+		ip.z[j] = math.Min(math.Max(a, lower+1.0), upper-1.0)
+	}
+
+	for i := range ip.normal {
+		ip.normal[i] = make([]float64, nRows)
+	}
+
+	return ip
+}
+
+// scatter adds n times column j of `[A -I]` to the dense vector v.
+func (ip *interior) scatter(j int, n float64, v []float64) {
+
+	if j >= ip.nStructurals {
+		v[j-ip.nStructurals] -= n
+		return
+	}
+
+	rows, values := ip.matrix.column(j)
+	for k, i := range rows {
+		v[i] += n * values[k]
+	}
+}
+
+// dot returns the product of column j of `[A -I]` with the dense vector y.
+func (ip *interior) dot(j int, y []float64) float64 {
+
+	if j >= ip.nStructurals {
+		return -y[j-ip.nStructurals]
+	}
+
+	rows, values := ip.matrix.column(j)
+	s := 0.0
+	for k, i := range rows {
+		s += values[k] * y[i]
+	}
+
+	return s
+}
+
+// gaps returns the distances of column j to its lower and upper bounds, zero
+// for a bound that is infinite. A column that rounding put on its bound is
+// kept a little away from it.
+func (ip *interior) gaps(j int) (float64, float64) {
+
+	wl, wu := 0.0, 0.0
+	if ip.hasLower(j) {
+		wl = math.Max(ip.z[j]-ip.lower[j], epsilon*epsilon)
+	}
+	if ip.hasUpper(j) {
+		wu = math.Max(ip.upper[j]-ip.z[j], epsilon*epsilon)
+	}
+
+	return wl, wu
+}
+
+func (ip *interior) hasLower(j int) bool {
+	return !ip.fixed[j] && !math.IsInf(ip.lower[j], -1)
+}
+
+func (ip *interior) hasUpper(j int) bool {
+	return !ip.fixed[j] && !math.IsInf(ip.upper[j], 1)
+}
+
+// residuals computes the primal and dual residuals and returns their largest
+// entries, the complementarity mu and the number of finite bounds.
+func (ip *interior) residuals() (float64, float64, float64, int) {
+
+	for i := range ip.rp {
+		ip.rp[i] = 0.0
+	}
+	for j, z := range ip.z {
+		if z != 0.0 {
+			ip.scatter(j, -z, ip.rp)
+		}
+	}
+
+	primal, dual := 0.0, 0.0
+	for _, r := range ip.rp {
+		primal = math.Max(primal, math.Abs(r))
+	}
+
+	complementarity := 0.0
+	bounds := 0
+
+	for j := range ip.z {
+		ip.rd[j] = 0.0
+		if ip.fixed[j] {
+			continue
+		}
+		ip.rd[j] = ip.cost[j] - ip.dot(j, ip.y) - ip.sl[j] + ip.su[j]
+		if ip.quadratic != nil {
+			ip.rd[j] += ip.quadratic[j] * ip.z[j]
+		}
+		dual = math.Max(dual, math.Abs(ip.rd[j]))
+
+		wl, wu := ip.gaps(j)
+		if ip.hasLower(j) {
+			complementarity += wl * ip.sl[j]
+			bounds++
+		}
+		if ip.hasUpper(j) {
+			complementarity += wu * ip.su[j]
+			bounds++
+		}
+	}
+
+	if bounds == 0 {
+		return primal, dual, 0.0, 0
+	}
+
+	return primal, dual, complementarity / float64(bounds), bounds
+}
+
+// factorize forms the normal equations of the current iterate and factorizes
+// them.
+func (ip *interior) factorize() {
+
+	for _, row := range ip.normal {
+		for k := range row {
+			row[k] = 0.0
+		}
+	}
+
+	for j := range ip.z {
+
+		ip.dinv[j] = 0.0
+		if ip.fixed[j] {
+			continue
+		}
+
+		wl, wu := ip.gaps(j)
+		d := regularization
+		if ip.quadratic != nil {
+			d += ip.quadratic[j]
+		}
+		if ip.hasLower(j) {
+			d += ip.sl[j] / wl
+		}
+		if ip.hasUpper(j) {
+			d += ip.su[j] / wu
+		}
+		ip.dinv[j] = 1.0 / d
+
+		if j >= ip.nStructurals {
+			i := j - ip.nStructurals
+			ip.normal[i][i] += ip.dinv[j]
+			continue
+		}
+
+		rows, values := ip.matrix.column(j)
+		for a, i := range rows {
+			for b, k := range rows[:a+1] {
+				if k > i {
+					ip.normal[k][i] += ip.dinv[j] * values[a] * values[b]
+				} else {
+					ip.normal[i][k] += ip.dinv[j] * values[a] * values[b]
+				}
+			}
+		}
+	}
+
+	for i := range ip.normal {
+		ip.normal[i][i] += regularization
+	}
+
+	cholesky(ip.normal)
+}
+
+// direction solves the Newton equations of the current iterate for given
+// right hand sides of complementarity, rl for lower and ru for upper bounds.
+func (ip *interior) direction(rl []float64, ru []float64) ([]float64, []float64, []float64, []float64) {
+
+	n := len(ip.z)
+
+	g := make([]float64, n)
+	for j := range g {
+		if ip.fixed[j] {
+			continue
+		}
+		wl, wu := ip.gaps(j)
+		g[j] = ip.rd[j]
+		if ip.hasLower(j) {
+			g[j] -= rl[j] / wl
+		}
+		if ip.hasUpper(j) {
+			g[j] += ru[j] / wu
+		}
+	}
+
+	dy := make([]float64, ip.nRows)
+	copy(dy, ip.rp)
+	for j := range g {
+		if g[j] != 0.0 && ip.dinv[j] != 0.0 {
+			ip.scatter(j, ip.dinv[j]*g[j], dy)
+		}
+	}
+	choleskySolve(ip.normal, dy)
+
+	dz := make([]float64, n)
+	dsl := make([]float64, n)
+	dsu := make([]float64, n)
+
+	for j := range dz {
+		if ip.fixed[j] {
+			continue
+		}
+		dz[j] = ip.dinv[j] * (ip.dot(j, dy) - g[j])
+		wl, wu := ip.gaps(j)
+		if ip.hasLower(j) {
+			dsl[j] = (rl[j] - ip.sl[j]*dz[j]) / wl
+		}
+		if ip.hasUpper(j) {
+			dsu[j] = (ru[j] + ip.su[j]*dz[j]) / wu
+		}
+	}
+
+	return dz, dy, dsl, dsu
+}
+
+// steps returns the longest primal and dual steps along a direction that keep
+// the iterate within its bounds and the duals of bounds non-negative.
+func (ip *interior) steps(dz []float64, dsl []float64, dsu []float64) (float64, float64) {
+
+	primal, dual := 1.0/stepScale, 1.0/stepScale
+
+	for j := range dz {
+		if ip.fixed[j] {
+			continue
+		}
+		wl, wu := ip.gaps(j)
+		if ip.hasLower(j) {
+			if dz[j] < 0.0 {
+				primal = math.Min(primal, -wl/dz[j])
+			}
+			if dsl[j] < 0.0 {
+				dual = math.Min(dual, -ip.sl[j]/dsl[j])
+			}
+		}
+		if ip.hasUpper(j) {
+			if dz[j] > 0.0 {
+				primal = math.Min(primal, wu/dz[j])
+			}
+			if dsu[j] < 0.0 {
+				dual = math.Min(dual, -ip.su[j]/dsu[j])
+			}
+		}
+	}
+
+	return primal, dual
+}
+
+// solve runs the method until it converges, which it reports as Optimal, or a
+// limit stops it, reported as LimitReached. MaxIterations is such a limit
+// when it is below interiorIterations. Zero means it gave up without
+// converging, which it does on infeasible and unbounded problems. Then
+// unbounded tells whether the iterates grew without limit or stalled feasible,
+// which on a feasible problem means it is unbounded.
+func (ip *interior) solve(ctx context.Context, options Options) Status {
+
+	var deadline time.Time
+	if options.TimeLimit > 0 {
+		deadline = time.Now().Add(options.TimeLimit)
+	}
+
+	costNorm, boundNorm := 0.0, 0.0
+	for j, c := range ip.cost {
+		costNorm = math.Max(costNorm, math.Abs(c))
+		for _, b := range []float64{ip.lower[j], ip.upper[j]} {
+			if isFinite(b) {
+				boundNorm = math.Max(boundNorm, math.Abs(b))
+			}
+		}
+	}
+
+	limit := interiorIterations
+	if options.MaxIterations > 0 && options.MaxIterations < limit {
+		limit = options.MaxIterations
+	}
+
+	n := len(ip.z)
+	rl := make([]float64, n)
+	ru := make([]float64, n)
+
+	for ; ip.iterations < limit; ip.iterations++ {
+
+		primal, dual, mu, bounds := ip.residuals()
+
+		size := 0.0
+		objective := 0.0
+		for j, z := range ip.z {
+			size = math.Max(size, math.Abs(z))
+			objective += ip.cost[j] * z
+			if ip.quadratic != nil {
+				objective += 0.5 * ip.quadratic[j] * z * z
+			}
+		}
+		for _, y := range ip.y {
+			size = math.Max(size, math.Abs(y))
+		}
+		if !isFinite(size) || !isFinite(mu) || size > interiorDivergence {
+			ip.unbounded = true
+			return 0
+		}
+
+		if primal <= interiorTolerance*(1.0+boundNorm) && dual <= interiorTolerance*(1.0+costNorm) &&
+			mu*float64(bounds) <= interiorTolerance*(1.0+math.Abs(objective)) {
+			return Optimal
+		}
+
+		// Complementarity that vanishes short of feasibility is stuck.
+		if mu*float64(bounds) <= interiorTolerance*interiorTolerance*(1.0+math.Abs(objective)) {
+			ip.unbounded = primal <= interiorTolerance*(1.0+boundNorm)
+			return 0
+		}
+
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return LimitReached
+		}
+		select {
+		case <-ctx.Done():
+			return LimitReached
+		default:
+		}
+
+		ip.factorize()
+
+		// The predictor aims straight at the boundary.
+		for j := range ip.z {
+			wl, wu := ip.gaps(j)
+			rl[j], ru[j] = -wl*ip.sl[j], -wu*ip.su[j]
+		}
+		dz, _, dsl, dsu := ip.direction(rl, ru)
+		primalStep, dualStep := ip.steps(dz, dsl, dsu)
+		primalStep, dualStep = math.Min(primalStep, 1.0), math.Min(dualStep, 1.0)
+
+		// Centering is weighed by how much the predictor would reduce mu, and
+		// the corrector makes up for the second order term.
+		predicted := 0.0
+		for j := range ip.z {
+			if ip.fixed[j] {
+				continue
+			}
+			wl, wu := ip.gaps(j)
+			if ip.hasLower(j) {
+				predicted += (wl + primalStep*dz[j]) * (ip.sl[j] + dualStep*dsl[j])
+			}
+			if ip.hasUpper(j) {
+				predicted += (wu - primalStep*dz[j]) * (ip.su[j] + dualStep*dsu[j])
+			}
+		}
+
+		sigma := 0.0
+		if bounds > 0 && mu > 0.0 {
+			sigma = math.Pow(predicted/float64(bounds)/mu, 3.0)
+			sigma = math.Min(sigma, 1.0)
+		}
+
+		for j := range ip.z {
+			if ip.fixed[j] {
+				continue
+			}
+			wl, wu := ip.gaps(j)
+			if ip.hasLower(j) {
+				rl[j] = sigma*mu - wl*ip.sl[j] - dz[j]*dsl[j]
+			}
+			if ip.hasUpper(j) {
+				ru[j] = sigma*mu - wu*ip.su[j] + dz[j]*dsu[j]
+			}
+		}
+
+		dz, dy, dsl, dsu := ip.direction(rl, ru)
+		primalStep, dualStep = ip.steps(dz, dsl, dsu)
+		primalStep, dualStep = math.Min(stepScale*primalStep, 1.0), math.Min(stepScale*dualStep, 1.0)
+
+		for j := range ip.z {
+			ip.z[j] += primalStep * dz[j]
+			ip.sl[j] += dualStep * dsl[j]
+			ip.su[j] += dualStep * dsu[j]
+		}
+		for i := range ip.y {
+			ip.y[i] += dualStep * dy[i]
+		}
+	}
+
+	if limit < interiorIterations {
+		return LimitReached
+	}
+
+	return 0
+}
+
+// values returns the structurals of the iterate, within their bounds.
+func (ip *interior) values() []float64 {
+
+	values := make([]float64, ip.nStructurals)
+	for j := range values {
+		values[j] = math.Min(math.Max(ip.z[j], ip.lower[j]), ip.upper[j])
+	}
+
+	return values
+}
+
+// result returns the optimal point of p the method converged to. Duals and
+// reduced costs come from the duals of the method, ranges are not reported
+// as there is no basis to range.
+func (ip *interior) result(p problem) Result {
+
+	sign := 1.0
+	if p.sense == Maximization {
+		sign = -1.0
+	}
+
+	result := Result{Status: Optimal, Values: ip.values(), Iterations: ip.iterations}
+
+	for j, x := range result.Values {
+		result.Objective += p.objective[j] * x
+		if ip.quadratic != nil {
+			result.Objective += sign * 0.5 * ip.quadratic[j] * x * x
+		}
+	}
+
+	result.Duals = make([]float64, p.nConstraints)
+	for i, c := range p.constraints {
+		result.Duals[c] = sign * ip.y[i]
+	}
+
+	result.ReducedCosts = make([]float64, ip.nStructurals)
+	for j := range result.ReducedCosts {
+		result.ReducedCosts[j] = sign * (ip.cost[j] - ip.dot(j, ip.y))
+		if ip.quadratic != nil {
+			result.ReducedCosts[j] += sign * ip.quadratic[j] * result.Values[j]
+		}
+	}
+
+	return result
+}
+
+// crossover returns a solver at a basic solution of p that is as good as the
+// point the method converged to. Values within the snap tolerance of a bound
+// are put on it, and the structurals left strictly between their bounds are
+// pushed onto a bound or into the basis. The simplex method then finishes
+// from there, which usually takes few pivots.
+func (ip *interior) crossover(ctx context.Context, p problem, options Options) (*solver, error) {
+
+	values := ip.values()
+	for j, x := range values {
+		lower, upper := p.lower[j], p.upper[j]
+		if isFinite(lower) && x-lower <= snapTolerance*(1.0+math.Abs(lower)) {
+			values[j] = lower
+		} else if isFinite(upper) && upper-x <= snapTolerance*(1.0+math.Abs(upper)) {
+			values[j] = upper
+		}
+	}
+
+	t, e := newSolverAt(p, values)
+	if e != nil {
+		return nil, e
+	}
+	t.configure(ctx, options)
+
+	t.setObjective(p)
+	if e := t.push(); e != nil {
+		return nil, e
+	}
+
+	// Phase 1 starts from zero costs.
+	for j := range t.cost {
+		t.cost[j] = 0.0
+	}
+
+	return t, nil
+}
+
+// push moves every structural that is strictly between its bounds, so that
+// it is neither basic nor at a bound, in the direction that does not worsen
+// the costs until it reaches its bound or a basic column reaches one and
+// leaves the basis for it.
+func (t *solver) push() error {
+
+	for j := 0; j < t.nStructurals; j++ {
+
+		if t.basic[j] || t.x[j] == t.lower[j] || t.x[j] == t.upper[j] {
+			continue
+		}
+
+		if t.factor.stale() {
+			if e := t.refactor(); e != nil {
+				return e
+			}
+		}
+		t.price()
+
+		direction := 1.0
+		if d := t.reduced[j]; d > 0.0 || (d == 0.0 && t.x[j]-t.lower[j] < t.upper[j]-t.x[j]) {
+			direction = -1.0
+		}
+
+		alpha := t.column(j)
+
+		row, step := t.ratio(j, direction, alpha)
+		if math.IsInf(step, 1) {
+			direction = -direction
+			row, step = t.ratio(j, direction, alpha)
+		}
+		// A free column that nothing limits is left to the simplex method.
+		if math.IsInf(step, 1) {
+			continue
+		}
+
+		t.move(j, direction, step, alpha)
+
+		if row == -1 {
+			if direction > 0.0 {
+				t.x[j] = t.upper[j]
+			} else {
+				t.x[j] = t.lower[j]
+			}
+		} else {
+			leaving := t.basis[row]
+			if -direction*alpha[row] < 0.0 {
+				t.x[leaving] = t.lower[leaving]
+			} else {
+				t.x[leaving] = t.upper[leaving]
+			}
+			t.pivot(row, j, alpha)
+		}
+
+		t.iterations++
+	}
+
+	return t.refactor()
+}
+
+// interiorStart runs the interior point method on p and returns its result
+// when that ends the solve: when a limit stops it, or when it converges and
+// no basis is wanted. Otherwise it returns a configured solver to finish with
+// the simplex method, after crossover when the method converged and from
+// scratch when it did not, along with the iterations of the method.
+func interiorStart(ctx context.Context, p problem, options Options, basis bool) (*solver, Result, error) {
+
+	ip := newInterior(p)
+	status := ip.solve(ctx, options)
+
+	switch {
+	case status == LimitReached:
+		return nil, Result{Status: LimitReached, Iterations: ip.iterations}, ctx.Err()
+	case status == Optimal && !basis:
+		return nil, ip.result(p), nil
+	}
+
+	result := Result{Iterations: ip.iterations}
+
+	if status == Optimal {
+		t, e := ip.crossover(ctx, p, options)
+		return t, result, e
+	}
+
+	t, e := newSolver(p)
+	if e != nil {
+		return nil, result, e
+	}
+	t.configure(ctx, options)
+
+	return t, result, nil
+}
+
+// cholesky factorizes the symmetric positive definite matrix a, given by its
+// lower triangle, into `L·Lᵀ` with L in the lower triangle. A pivot that
+// vanishes, as those of dependent rows do, is replaced by a huge one, which
+// drops its direction from solves.
+func cholesky(a [][]float64) {
+
+	largest := 0.0
+	for i := range a {
+		largest = math.Max(largest, a[i][i])
+	}
+
+	for k := range a {
+
+		d := a[k][k]
+		for p := 0; p < k; p++ {
+			d -= a[k][p] * a[k][p]
+		}
+		if d <= epsilon*epsilon*largest {
+			d = 1e128
+		}
+		d = math.Sqrt(d)
+		a[k][k] = d
+
+		for i := k + 1; i < len(a); i++ {
+			s := a[i][k]
+			for p := 0; p < k; p++ {
+				s -= a[i][p] * a[k][p]
+			}
+			a[i][k] = s / d
+		}
+	}
+}
+
+// choleskySolve solves `L·Lᵀ·x = v` in place, L factorized by cholesky.
+func choleskySolve(l [][]float64, v []float64) {
+
+	for i := range v {
+		s := v[i]
+		for k := 0; k < i; k++ {
+			s -= l[i][k] * v[k]
+		}
+		v[i] = s / l[i][i]
+	}
+
+	for i := len(v) - 1; i >= 0; i-- {
+		s := v[i]
+		for k := i + 1; k < len(v); k++ {
+			s -= l[k][i] * v[k]
+		}
+		v[i] = s / l[i][i]
+	}
+}
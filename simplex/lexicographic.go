@@ -0,0 +1,204 @@
+package simplex
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Objective is a stage of a lexicographic solve: Expr optimized in Sense.
+// When Violations is set, the penalties of soft constraints the solution
+// violates are added to a minimized Expr or taken from a maximized one.
+//
+// Tolerance is how much later stages may worsen the optimum of the stage,
+// relative to its size and at least the feasibility tolerance.
+//
+// Squares are added to a minimized Expr or taken from a maximized one, as in
+// SolveQuadratic. Only the last objective may have them, and the values of
+// integer variables it starts from are kept.
+type Objective struct {
+	Expr       Expr
+	Sense      Sense
+	Tolerance  float64
+	Violations bool
+	Squares    []Square
+}
+
+// SolveLexicographic optimizes objectives of m in order, the objective of
+// the model itself aside. Every stage after the first keeps each earlier
+// objective within its tolerance of the optimum it reached, so a later
+// objective only decides between solutions that are as good as each other by
+// the earlier ones.
+//
+// Options apply to every stage, except TimeLimit, which limits the whole
+// solve, Start, which only starts the first stage, as every later stage starts
+// from the solution of the one before, and Incumbent, which is only called by
+// the last stage. A stage that does not reach optimality ends the solve with
+// its result, Values the best solution it found if there is one.
+func SolveLexicographic(ctx context.Context, m *Model, objectives []Objective, options Options) (*Solution, error) {
+
+	solution := &Solution{model: m}
+
+	if m.e != nil {
+		return solution, m.e
+	}
+	if e := options.check(); e != nil {
+		return solution, e
+	}
+	if len(objectives) == 0 {
+		return solution, fmt.Errorf("%w: no objectives", ErrOptions)
+	}
+	for k, o := range objectives {
+		if e := m.checkObjective(o); e != nil {
+			return solution, fmt.Errorf("%w: objective %d", e, k+1)
+		}
+		if len(o.Squares) > 0 && k < len(objectives)-1 {
+			return solution, fmt.Errorf("%w: squares in objective %d before the last", ErrOptions, k+1)
+		}
+	}
+
+	var deadline time.Time
+	if options.TimeLimit > 0 {
+		deadline = time.Now().Add(options.TimeLimit)
+	}
+
+	p := m.problem()
+	n := len(m.variables)
+
+	// Elastic columns carry the penalties in the sense of the model, stages
+	// put them in their own.
+	penalties := make([]float64, len(p.objective))
+	for j := n; j < len(penalties); j++ {
+		penalties[j] = math.Abs(p.objective[j])
+	}
+
+	costs := make([][]float64, len(objectives))
+	for k, o := range objectives {
+		costs[k] = make([]float64, len(p.objective))
+		for v, x := range o.Expr {
+			costs[k][v] = x
+		}
+		if o.Violations {
+			sign := 1.0
+			if o.Sense == Maximization {
+				sign = -1.0
+			}
+			for j := n; j < len(penalties); j++ {
+				costs[k][j] = sign * penalties[j]
+			}
+		}
+	}
+
+	elastic := m.elasticOptions(p, options)
+	start := elastic.Start
+
+	result := Result{}
+	iterations, nodes := 0, 0
+
+	for k, o := range objectives {
+
+		p.sense = o.Sense
+		p.objective = costs[k]
+
+		stageOptions := options
+		stageOptions.Start = start
+		stageOptions.Incumbent = nil
+		if k == len(objectives)-1 {
+			stageOptions.Incumbent = elastic.Incumbent
+		}
+		if !deadline.IsZero() {
+			stageOptions.TimeLimit = time.Until(deadline)
+			if stageOptions.TimeLimit <= 0 {
+				stageOptions.TimeLimit = time.Nanosecond
+			}
+		}
+
+		var e error
+		if len(o.Squares) > 0 {
+			result, e = quadraticStage(ctx, p, o.Squares, start, stageOptions)
+		} else {
+			result, e = solveProblem(ctx, p, stageOptions)
+		}
+		iterations += result.Iterations
+		nodes += result.Nodes
+		result.Iterations, result.Nodes = iterations, nodes
+
+		if e != nil || result.Status != Optimal || k == len(objectives)-1 {
+			if result.Values != nil {
+				result.Objectives = stageValues(costs, result.Values)
+				result.Objectives[k] = result.Objective
+			}
+			solution.Result = m.hideElastic(result)
+			return solution, e
+		}
+
+		// The row keeps the objective within its tolerance of the optimum.
+		optimum := result.Objective
+		slack := math.Max(o.Tolerance, options.feasibilityTolerance()) * math.Max(1.0, math.Abs(optimum))
+		lower, upper := math.Inf(-1), optimum+slack
+		if o.Sense == Maximization {
+			lower, upper = optimum-slack, math.Inf(1)
+		}
+
+		p.matrix.appendRow(costs[k])
+		p.rowLower = append(p.rowLower, lower)
+		p.rowUpper = append(p.rowUpper, upper)
+		p.constraints = append(p.constraints, p.nConstraints)
+		p.nConstraints++
+
+		start = result.Values
+	}
+
+	return solution, nil
+}
+
+// checkObjective validates an objective of a lexicographic solve of m.
+func (m *Model) checkObjective(o Objective) error {
+
+	for v, x := range o.Expr {
+		if v < 0 || int(v) >= len(m.variables) {
+			return ErrUnknownVar
+		}
+		if !isFinite(x) {
+			return ErrInvalidValue
+		}
+	}
+	if o.Sense != Maximization && o.Sense != Minimization {
+		return fmt.Errorf("%w: objective sense", ErrInvalidValue)
+	}
+	if !isFinite(o.Tolerance) || o.Tolerance < 0.0 {
+		return fmt.Errorf("%w: tolerance %g", ErrInvalidValue, o.Tolerance)
+	}
+	for _, s := range o.Squares {
+		if e := m.checkSquare(s); e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// quadraticStage solves p with squares, keeping the values of integer
+// columns in start.
+func quadraticStage(ctx context.Context, p problem, squares []Square, start []float64, options Options) (Result, error) {
+	if p.integer != nil && len(start) != len(p.objective) {
+		return Result{}, fmt.Errorf("%w: integer variables need a start", ErrOptions)
+	}
+	return solveQuadratic(ctx, p, squares, start, options)
+}
+
+// stageValues returns the value of every stage objective given by its costs
+// at values.
+func stageValues(costs [][]float64, values []float64) []float64 {
+
+	objectives := make([]float64, len(costs))
+
+	for k, c := range costs {
+		for j, x := range values {
+			objectives[k] += c[j] * x
+		}
+	}
+
+	return objectives
+}
@@ -0,0 +1,188 @@
+package simplex
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestSolveLexicographic(t *testing.T) {
+
+	inf := math.Inf(1)
+
+	// build returns a model of x and y in [0, 10], and y up to upper, with
+	// x + y <= 10 and, when soft, x >= 4 at penalty 1.
+	build := func(upper float64, soft bool) (*Model, Var, Var) {
+		m := NewModel()
+		x := m.AddVar("x", 0, 10)
+		y := m.AddVar("y", 0, upper)
+		if upper <= 10 {
+			m.AddConstraint("sum", Expr{x: 1, y: 1}, LE, 10)
+		}
+		if soft {
+			m.SetPenalty(m.AddConstraint("least", Expr{x: 1}, GE, 4), 1)
+		}
+		return m, x, y
+	}
+
+	tests := []struct {
+		name       string
+		upper      float64
+		soft       bool
+		infeasible bool
+		stages     func(x Var, y Var) []Objective
+
+		status     Status
+		values     []float64
+		objectives []float64
+	}{
+		{
+			// Every point with x + y = 10 is optimal for the first
+			// objective, and the second picks the one of most x.
+			name:  "ties broken",
+			upper: 10,
+			stages: func(x Var, y Var) []Objective {
+				return []Objective{
+					{Expr: Expr{x: 1, y: 1}, Sense: Maximization},
+					{Expr: Expr{x: 1}, Sense: Maximization},
+				}
+			},
+			status:     Optimal,
+			values:     []float64{10, 0},
+			objectives: []float64{10, 10},
+		},
+		{
+			// The second objective would rather have x and y at zero, but it
+			// may not worsen the first.
+			name:  "first kept",
+			upper: 10,
+			stages: func(x Var, y Var) []Objective {
+				return []Objective{
+					{Expr: Expr{x: 1, y: 1}, Sense: Maximization},
+					{Expr: Expr{x: 2, y: 1}, Sense: Minimization},
+				}
+			},
+			status:     Optimal,
+			values:     []float64{0, 10},
+			objectives: []float64{10, 10},
+		},
+		{
+			name:  "tolerance",
+			upper: 10,
+			stages: func(x Var, y Var) []Objective {
+				return []Objective{
+					{Expr: Expr{x: 1, y: 1}, Sense: Maximization, Tolerance: 0.1},
+					{Expr: Expr{x: 1, y: 1}, Sense: Minimization},
+				}
+			},
+			status:     Optimal,
+			objectives: []float64{9, 9},
+		},
+		{
+			// With violations the first objective is the same anywhere in
+			// [0, 4], which the second breaks.
+			name:  "violations",
+			upper: 10,
+			soft:  true,
+			stages: func(x Var, y Var) []Objective {
+				return []Objective{
+					{Expr: Expr{x: 1}, Sense: Minimization, Violations: true},
+					{Expr: Expr{x: 1, y: -1}, Sense: Maximization},
+				}
+			},
+			status:     Optimal,
+			values:     []float64{4, 0},
+			objectives: []float64{4, 4},
+		},
+		{
+			name:       "first infeasible",
+			upper:      10,
+			infeasible: true,
+			stages: func(x Var, y Var) []Objective {
+				return []Objective{
+					{Expr: Expr{x: 1}, Sense: Maximization},
+					{Expr: Expr{y: 1}, Sense: Maximization},
+				}
+			},
+			status: Infeasible,
+		},
+		{
+			name:  "first unbounded",
+			upper: inf,
+			stages: func(x Var, y Var) []Objective {
+				return []Objective{
+					{Expr: Expr{y: 1}, Sense: Maximization},
+					{Expr: Expr{x: 1}, Sense: Maximization},
+				}
+			},
+			status: Unbounded,
+		},
+		{
+			name:  "second unbounded",
+			upper: inf,
+			stages: func(x Var, y Var) []Objective {
+				return []Objective{
+					{Expr: Expr{x: 1}, Sense: Maximization},
+					{Expr: Expr{y: 1}, Sense: Maximization},
+				}
+			},
+			status: Unbounded,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			m, x, y := build(test.upper, test.soft)
+			if test.infeasible {
+				m.AddConstraint("too much", Expr{x: 1, y: 1}, GE, 30)
+			}
+
+			s, e := SolveLexicographic(context.Background(), m, test.stages(x, y), Options{})
+			if e != nil {
+				t.Fatal(e)
+			}
+			if s.Status != test.status {
+				t.Fatalf("status %v, want %v", s.Status, test.status)
+			}
+			if s.Status != Optimal {
+				return
+			}
+
+			if test.values != nil && !nearAll(s.Values, test.values) {
+				t.Errorf("values %v, want %v", s.Values, test.values)
+			}
+			if !nearAll(s.Objectives, test.objectives) {
+				t.Errorf("objectives %v, want %v", s.Objectives, test.objectives)
+			}
+			if !near(s.Objective, test.objectives[len(test.objectives)-1]) {
+				t.Errorf("objective %g, want the last of %v", s.Objective, test.objectives)
+			}
+		})
+	}
+}
+
+func TestSolveLexicographicInvalid(t *testing.T) {
+
+	m := NewModel()
+	x := m.AddVar("x", 0, 10)
+
+	tests := map[string][]Objective{
+		"no objectives":       nil,
+		"squares before last": {{Expr: Expr{x: 1}, Sense: Minimization, Squares: []Square{{Expr: Expr{x: 1}, Weight: 1}}}, {Expr: Expr{x: 1}, Sense: Minimization}},
+		"negative tolerance":  {{Expr: Expr{x: 1}, Sense: Minimization, Tolerance: -1}},
+		"unknown variable":    {{Expr: Expr{x + 1: 1}, Sense: Minimization}},
+		"no sense":            {{Expr: Expr{x: 1}}},
+	}
+
+	for name, objectives := range tests {
+		if _, e := SolveLexicographic(context.Background(), m, objectives, Options{}); e == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+
+	if _, e := SolveLexicographic(context.Background(), m, nil, Options{}); !errors.Is(e, ErrOptions) {
+		t.Errorf("error %v, want %v", e, ErrOptions)
+	}
+}
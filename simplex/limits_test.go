@@ -0,0 +1,165 @@
+package simplex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+// textbook returns max 3x + 5y subject to x <= 4, 2y <= 12 and 3x + 2y <= 18,
+// optimal at x = 2, y = 6. Its slack basis is feasible, so phase 2 starts
+// right away.
+func textbook() *Model {
+	m := NewModel()
+	x := m.AddVar("x", 0, math.Inf(1))
+	y := m.AddVar("y", 0, math.Inf(1))
+	m.AddConstraint("plant1", Expr{x: 1}, LE, 4)
+	m.AddConstraint("plant2", Expr{y: 2}, LE, 12)
+	m.AddConstraint("plant3", Expr{x: 3, y: 2}, LE, 18)
+	m.Maximize(Expr{x: 3, y: 5})
+	return m
+}
+
+// knapsack returns a choice of items of most value within a weight, whose
+// relaxation is fractional, with its variables.
+func knapsack() (*Model, []Var) {
+
+	m := NewModel()
+
+	values := []float64{10, 13, 7, 4, 9, 6, 11, 8, 5, 12, 3, 14}
+	weights := []float64{4, 6, 3, 2, 5, 3, 5, 4, 3, 6, 2, 7}
+
+	items := make([]Var, len(values))
+	objective, weight := Expr{}, Expr{}
+	for j := range values {
+		items[j] = m.AddBinaryVar(fmt.Sprint("item", j))
+		objective[items[j]] = values[j]
+		weight[items[j]] = weights[j]
+	}
+	m.AddConstraint("weight", weight, LE, 23.5)
+	m.Maximize(objective)
+
+	return m, items
+}
+
+func TestCanceled(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	lp := textbook()
+	mip, _ := knapsack()
+
+	tests := []struct {
+		name    string
+		m       *Model
+		options Options
+	}{
+		{"simplex", lp, Options{}},
+		{"interior point", lp, Options{Method: InteriorPoint}},
+		{"crossover", lp, Options{Method: InteriorPoint, Crossover: true}},
+		{"branch-and-bound", mip, Options{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, e := SolveContext(ctx, test.m, test.options)
+			if !errors.Is(e, context.Canceled) || e != ctx.Err() {
+				t.Errorf("error %v, want %v", e, ctx.Err())
+			}
+			if s.Status != LimitReached {
+				t.Errorf("status %v", s.Status)
+			}
+		})
+	}
+}
+
+func TestMaxIterations(t *testing.T) {
+
+	s, e := Solve(textbook(), Options{MaxIterations: 1})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if s.Status != LimitReached || s.Iterations != 1 {
+		t.Fatalf("status %v after %d iterations", s.Status, s.Iterations)
+	}
+
+	// Dantzig's rule brings in y first, which plant2 stops at 6.
+	if !nearAll(s.Values, []float64{0, 6}) || !near(s.Objective, 30) {
+		t.Errorf("objective %g at %v, want 30 at [0 6]", s.Objective, s.Values)
+	}
+	if s.Duals != nil || s.ObjectiveRanges != nil {
+		t.Errorf("duals %v and ranges %v of a basis that is not optimal", s.Duals, s.ObjectiveRanges)
+	}
+
+	s, e = Solve(textbook(), Options{Method: InteriorPoint, MaxIterations: 1})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if s.Status != LimitReached || s.Iterations != 1 {
+		t.Errorf("interior point: status %v after %d iterations", s.Status, s.Iterations)
+	}
+}
+
+func TestTimeLimit(t *testing.T) {
+
+	lp := textbook()
+	mip, _ := knapsack()
+
+	for _, m := range []*Model{lp, mip} {
+		for _, method := range []Method{SimplexMethod, InteriorPoint} {
+			s, e := Solve(m, Options{Method: method, TimeLimit: time.Nanosecond})
+			if e != nil {
+				t.Fatal(e)
+			}
+			if s.Status != LimitReached {
+				t.Errorf("%v: status %v", method, s.Status)
+			}
+		}
+	}
+}
+
+func TestNodeLimit(t *testing.T) {
+
+	m, items := knapsack()
+
+	s, e := m.Solve()
+	if e != nil {
+		t.Fatal(e)
+	}
+	if s.Status != Optimal || s.Nodes <= 3 {
+		t.Fatalf("status %v after %d nodes, the limit would not stop the search", s.Status, s.Nodes)
+	}
+	optimum := s.Objective
+
+	// The first and the last items are a solution worth 24.
+	start := make([]float64, len(items))
+	start[0], start[len(items)-1] = 1, 1
+
+	s, e = Solve(m, Options{NodeLimit: 3, Start: start})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if s.Status != LimitReached || s.Nodes != 3 {
+		t.Fatalf("status %v after %d nodes", s.Status, s.Nodes)
+	}
+	if s.Values == nil || s.Objective < 24 || s.Objective > optimum {
+		t.Fatalf("incumbent %g at %v, start 24 and optimum %g", s.Objective, s.Values, optimum)
+	}
+	weight := 0.0
+	for j, x := range s.Values {
+		if x != 0 && x != 1 {
+			t.Errorf("item%d = %g", j, x)
+		}
+		weight += x * m.constraints[0].expr[items[j]]
+	}
+	if weight > 23.5 {
+		t.Errorf("weight %g", weight)
+	}
+	if s.Bound < optimum-1e-9 {
+		t.Errorf("bound %g under the optimum %g", s.Bound, optimum)
+	}
+}
@@ -0,0 +1,628 @@
+package simplex
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// lpSections maps the words that start a section of an LP file to the
+// sections. "subject to" and "such that" take two words.
+var lpSections = map[string]string{
+	"maximize": "maximize",
+	"maximise": "maximize",
+	"maximum":  "maximize",
+	"max":      "maximize",
+	"minimize": "minimize",
+	"minimise": "minimize",
+	"minimum":  "minimize",
+	"min":      "minimize",
+	"st":       "constraints",
+	"s.t.":     "constraints",
+	"st.":      "constraints",
+	"bounds":   "bounds",
+	"bound":    "bounds",
+	"general":  "general",
+	"generals": "general",
+	"gen":      "general",
+	"integer":  "general",
+	"integers": "general",
+	"binary":   "binary",
+	"binaries": "binary",
+	"bin":      "binary",
+	"end":      "end",
+	"semi":     "unsupported",
+	"semis":    "unsupported",
+	"sos":      "unsupported",
+}
+
+// lpTermsPerLine is the number of terms written on a line of an LP file,
+// which keeps lines short enough for every reader.
+const lpTermsPerLine = 8
+
+// WriteLP writes the model in CPLEX LP format. A ranged constraint is written
+// as `name: lower <= expr <= upper`. Soft constraints are written with their
+// elastic columns and constraints that bound nothing are left out, so the
+// file holds the linear program the solver sees.
+//
+// Characters other than letters, digits, '_' and '.' in names are replaced by
+// underscores, names that would start with a digit or a '.' or be read as a
+// keyword get a '_' in front, and names that become equal get a number
+// after them.
+func (m *Model) WriteLP(w io.Writer) error {
+
+	if m.e != nil {
+		return m.e
+	}
+
+	l := m.lower()
+	b := bufio.NewWriter(w)
+
+	if l.sense == Maximization {
+		fmt.Fprintln(b, "Maximize")
+	} else {
+		fmt.Fprintln(b, "Minimize")
+	}
+
+	objectiveColumns := []int{}
+	objectiveValues := []float64{}
+	for j, c := range l.objective {
+		if c != 0.0 {
+			objectiveColumns = append(objectiveColumns, j)
+			objectiveValues = append(objectiveValues, c)
+		}
+	}
+	fmt.Fprint(b, " obj:")
+	writeTerms(b, l.columns, objectiveColumns, objectiveValues)
+	fmt.Fprintln(b)
+
+	fmt.Fprintln(b, "Subject To")
+	columns, values := l.rowEntries()
+	for i, name := range l.rows {
+		lower, upper := l.rowLower[i], l.rowUpper[i]
+		fmt.Fprintf(b, " %s:", name)
+		if lower != upper && !math.IsInf(lower, -1) && !math.IsInf(upper, 1) {
+			fmt.Fprintf(b, " %s <=", formatNumber(lower))
+		}
+		writeTerms(b, l.columns, columns[i], values[i])
+		switch {
+		case lower == upper:
+			fmt.Fprintf(b, " = %s\n", formatNumber(lower))
+		case math.IsInf(upper, 1):
+			fmt.Fprintf(b, " >= %s\n", formatNumber(lower))
+		default:
+			fmt.Fprintf(b, " <= %s\n", formatNumber(upper))
+		}
+	}
+
+	fmt.Fprintln(b, "Bounds")
+	for j, name := range l.columns {
+		lower, upper := l.lower[j], l.upper[j]
+		switch {
+		case lower == upper:
+			fmt.Fprintf(b, " %s = %s\n", name, formatNumber(lower))
+		case math.IsInf(lower, -1) && math.IsInf(upper, 1):
+			fmt.Fprintf(b, " %s free\n", name)
+		case lower == 0.0 && math.IsInf(upper, 1):
+		case lower == 0.0:
+			fmt.Fprintf(b, " %s <= %s\n", name, formatNumber(upper))
+		case math.IsInf(upper, 1):
+			fmt.Fprintf(b, " %s >= %s\n", name, formatNumber(lower))
+		default:
+			fmt.Fprintf(b, " %s <= %s <= %s\n", formatNumber(lower), name, formatNumber(upper))
+		}
+	}
+
+	if l.integer != nil {
+		fmt.Fprintln(b, "General")
+		for j, name := range l.columns {
+			if l.integer[j] {
+				fmt.Fprintf(b, " %s\n", name)
+			}
+		}
+	}
+
+	fmt.Fprintln(b, "End")
+
+	return b.Flush()
+}
+
+// writeTerms writes a linear expression over given columns, a zero term when
+// it has none so that every reader sees an expression.
+func writeTerms(b *bufio.Writer, names []string, columns []int, values []float64) {
+
+	if len(columns) == 0 && len(names) > 0 {
+		fmt.Fprintf(b, " 0 %s", names[0])
+		return
+	}
+
+	for k, j := range columns {
+		if k > 0 && k%lpTermsPerLine == 0 {
+			fmt.Fprint(b, "\n   ")
+		}
+		x := values[k]
+		sign := "+"
+		if x < 0.0 {
+			sign, x = "-", -x
+		}
+		if k == 0 && sign == "+" {
+			fmt.Fprintf(b, " %s %s", formatNumber(x), names[j])
+		} else {
+			fmt.Fprintf(b, " %s %s %s", sign, formatNumber(x), names[j])
+		}
+	}
+}
+
+// lpToken is a word, a number or an operator of an LP file with its line.
+type lpToken struct {
+	text string
+	line int
+}
+
+// lpReader parses the tokens of a section of an LP file.
+type lpReader struct {
+	tokens []lpToken
+	k      int
+	line   int
+}
+
+func (r *lpReader) done() bool {
+	return r.k >= len(r.tokens)
+}
+
+func (r *lpReader) peek(offset int) string {
+	if r.k+offset >= len(r.tokens) {
+		return ""
+	}
+	return r.tokens[r.k+offset].text
+}
+
+func (r *lpReader) next() string {
+	t := r.peek(0)
+	if !r.done() {
+		r.line = r.tokens[r.k].line
+		r.k++
+	}
+	return t
+}
+
+func (r *lpReader) fail(format string, args ...interface{}) error {
+	line := r.line
+	if !r.done() {
+		line = r.tokens[r.k].line
+	}
+	return formatError(line, format, args...)
+}
+
+// ReadLP reads a model in CPLEX LP format: an objective section, a Subject To
+// section, and optional Bounds, General and Binary sections, ended by End.
+// Constraints may be ranged, written `name: lower <= expr <= upper`, may have
+// no terms, as a model without columns has, written `name: >= 0`, and
+// constraints without a name are named `c<index>`. Variables are bounded by
+// `0 <= x <= +Inf` unless the Bounds section sets their bounds, and binary
+// ones by `0 <= x <= 1`. Objective constants, semi-continuous variables and
+// special ordered sets are not supported.
+func ReadLP(r io.Reader) (*Model, error) {
+
+	b := newBuilder()
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<24)
+
+	sections := map[string][]lpToken{}
+	section := ""
+	ended := false
+
+	for line := 1; scanner.Scan(); line++ {
+
+		text := scanner.Text()
+		if k := strings.IndexByte(text, '\\'); k >= 0 {
+			text = text[:k]
+		}
+		tokens := tokenizeLP(text, line)
+		if len(tokens) == 0 {
+			continue
+		}
+		if ended {
+			return nil, formatError(line, "data after End")
+		}
+
+		// A section starts with its word, unless the word names a row.
+		first := strings.ToLower(tokens[0].text)
+		words := 1
+		name, ok := lpSections[first]
+		if first == "subject" || first == "such" {
+			if len(tokens) > 1 && (strings.ToLower(tokens[1].text) == "to" || strings.ToLower(tokens[1].text) == "that") {
+				name, ok, words = "constraints", true, 2
+			}
+		}
+		if ok && (len(tokens) == words || tokens[words].text != ":") {
+			if name == "unsupported" {
+				return nil, formatError(line, "section %q is not supported", tokens[0].text)
+			}
+			if _, seen := sections[name]; seen || (name == "maximize" && sections["minimize"] != nil) || (name == "minimize" && sections["maximize"] != nil) {
+				return nil, formatError(line, "second %q section", tokens[0].text)
+			}
+			section = name
+			sections[section] = []lpToken{}
+			ended = section == "end"
+			tokens = tokens[words:]
+		}
+
+		if section == "" {
+			return nil, formatError(line, "data outside of a section")
+		}
+		sections[section] = append(sections[section], tokens...)
+	}
+
+	if e := scanner.Err(); e != nil {
+		return nil, e
+	}
+	if !ended {
+		return nil, fmt.Errorf("%w: no End", ErrFormat)
+	}
+
+	// Sections are parsed in order of dependency, as bounds and integrality
+	// apply to columns the objective and constraints introduce.
+	for _, section := range []string{"maximize", "minimize", "constraints", "bounds", "general", "binary"} {
+		tokens, ok := sections[section]
+		if !ok {
+			continue
+		}
+		r := &lpReader{tokens: tokens}
+		var e error
+		switch section {
+		case "maximize", "minimize":
+			b.sense = Minimization
+			if section == "maximize" {
+				b.sense = Maximization
+			}
+			e = readObjective(r, b)
+		case "constraints":
+			e = readConstraints(r, b)
+		case "bounds":
+			e = readBounds(r, b)
+		case "general", "binary":
+			for !r.done() {
+				j := b.column(r.next())
+				b.integer[j] = true
+				if section == "binary" {
+					b.lower[j], b.upper[j] = 0.0, 1.0
+				}
+			}
+		}
+		if e != nil {
+			return nil, e
+		}
+	}
+
+	return b.model()
+}
+
+// tokenizeLP splits a line of an LP file into words, numbers, relations,
+// signs and colons.
+func tokenizeLP(text string, line int) []lpToken {
+
+	tokens := []lpToken{}
+
+	for k := 0; k < len(text); {
+
+		c := text[k]
+		start := k
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			k++
+			continue
+		case c == '<' || c == '>' || c == '=':
+			k++
+			if k < len(text) && (text[k] == '=' || text[k] == '<' || text[k] == '>') {
+				k++
+			}
+		case c == '+' || c == '-' || c == ':':
+			k++
+		case (c >= '0' && c <= '9') || c == '.':
+			k++
+			for k < len(text) && ((text[k] >= '0' && text[k] <= '9') || text[k] == '.') {
+				k++
+			}
+			if k < len(text) && (text[k] == 'e' || text[k] == 'E') {
+				k++
+				if k < len(text) && (text[k] == '+' || text[k] == '-') {
+					k++
+				}
+				for k < len(text) && text[k] >= '0' && text[k] <= '9' {
+					k++
+				}
+			}
+		default:
+			for k < len(text) && !strings.ContainsRune(" \t\r<>=+-:", rune(text[k])) {
+				k++
+			}
+		}
+
+		tokens = append(tokens, lpToken{text[start:k], line})
+	}
+
+	return tokens
+}
+
+// isRelation reports whether t compares two sides.
+func isRelation(t string) bool {
+	return lpRelation(t) != 0
+}
+
+// lpRelation returns the relation written t, `<` and `>` meaning `<=` and
+// `>=`, or zero when t is none.
+func lpRelation(t string) Relation {
+	switch t {
+	case "<=", "=<", "<":
+		return LE
+	case ">=", "=>", ">":
+		return GE
+	case "=":
+		return EQ
+	}
+	return 0
+}
+
+// isNumberToken reports whether t is read as a number.
+func isNumberToken(t string) bool {
+	if t == "" {
+		return false
+	}
+	if (t[0] >= '0' && t[0] <= '9') || t[0] == '.' {
+		return true
+	}
+	lower := strings.ToLower(t)
+	return lower == "inf" || lower == "infinity"
+}
+
+// signedNumber reads a number with any signs before it.
+func (r *lpReader) signedNumber() (float64, error) {
+
+	sign := 1.0
+	for r.peek(0) == "+" || r.peek(0) == "-" {
+		if r.next() == "-" {
+			sign = -sign
+		}
+	}
+
+	t := r.next()
+	x, ok := parseNumber(t)
+	if !isNumberToken(t) || !ok {
+		return 0.0, r.fail("expected a number, found %q", t)
+	}
+
+	return sign * x, nil
+}
+
+// startsNumber reports whether a signed number starts at the current token.
+func (r *lpReader) startsNumber() bool {
+	return r.numberLength() > 0
+}
+
+// startsBound reports whether a signed number followed by a relation starts
+// at the current token, as a bound written before an expression does.
+func (r *lpReader) startsBound() bool {
+	k := r.numberLength()
+	return k > 0 && isRelation(r.peek(k))
+}
+
+// numberLength returns the number of tokens of the signed number at the
+// current token, zero when there is none.
+func (r *lpReader) numberLength() int {
+	k := 0
+	for r.peek(k) == "+" || r.peek(k) == "-" {
+		k++
+	}
+	if !isNumberToken(r.peek(k)) {
+		return 0
+	}
+	return k + 1
+}
+
+// expression reads terms up to a relation or the end of the section. A
+// number without a column is a constant, which is not supported.
+func (r *lpReader) expression(b *builder) (map[int]float64, error) {
+
+	expr := map[int]float64{}
+	first := true
+
+	for !r.done() && !isRelation(r.peek(0)) {
+
+		sign := 1.0
+		signed := false
+		for r.peek(0) == "+" || r.peek(0) == "-" {
+			signed = true
+			if r.next() == "-" {
+				sign = -sign
+			}
+		}
+		if !signed && !first {
+			break
+		}
+
+		coefficient := 1.0
+		if isNumberToken(r.peek(0)) {
+			t := r.next()
+			x, ok := parseNumber(t)
+			if !ok || math.IsInf(x, 0) {
+				return nil, r.fail("invalid coefficient %q", t)
+			}
+			coefficient = x
+		}
+
+		t := r.peek(0)
+		if t == "" || isRelation(t) || t == ":" || t == "+" || t == "-" || isNumberToken(t) {
+			return nil, r.fail("constants in expressions are not supported")
+		}
+		r.next()
+
+		expr[b.column(t)] += sign * coefficient
+		first = false
+	}
+
+	return expr, nil
+}
+
+// readObjective reads an objective with an optional name.
+func readObjective(r *lpReader, b *builder) error {
+
+	if r.peek(1) == ":" {
+		r.next()
+		r.next()
+	}
+
+	expr, e := r.expression(b)
+	if e != nil {
+		return e
+	}
+	if !r.done() {
+		return r.fail("unexpected %q in objective", r.peek(0))
+	}
+
+	b.objective = expr
+
+	return nil
+}
+
+// readConstraints reads constraints until the end of the section.
+func readConstraints(r *lpReader, b *builder) error {
+
+	for !r.done() {
+
+		name := fmt.Sprintf("c%d", len(b.rows))
+		if r.peek(1) == ":" {
+			name = r.next()
+			r.next()
+		}
+
+		i, ok := b.row(name)
+		if !ok {
+			return r.fail("duplicate row %q", name)
+		}
+
+		// A ranged row starts with its lower or upper bound.
+		var bound float64
+		var first Relation
+		if r.startsBound() {
+			var e error
+			bound, e = r.signedNumber()
+			if e != nil {
+				return e
+			}
+			t := r.next()
+			first = lpRelation(t)
+			if first != LE && first != GE {
+				return r.fail("expected an inequality, found %q", t)
+			}
+		}
+
+		expr, e := r.expression(b)
+		if e != nil {
+			return e
+		}
+		b.exprs[i] = expr
+
+		t := r.next()
+		relation := lpRelation(t)
+		if relation == 0 {
+			return r.fail("expected a relation, found %q", t)
+		}
+		rhs, e := r.signedNumber()
+		if e != nil {
+			return e
+		}
+
+		switch {
+		case first != 0 && first != relation:
+			return r.fail("relations of ranged row %q differ", name)
+		case first == LE:
+			b.rowLower[i], b.rowUpper[i] = bound, rhs
+		case first == GE:
+			b.rowLower[i], b.rowUpper[i] = rhs, bound
+		default:
+			b.rowLower[i], b.rowUpper[i] = relation.bounds(rhs)
+		}
+	}
+
+	return nil
+}
+
+// readBounds reads bounds of columns until the end of the section:
+// `x free`, `x relation number`, `number relation x`, and
+// `number relation x relation number`.
+func readBounds(r *lpReader, b *builder) error {
+
+	for !r.done() {
+
+		if !r.startsNumber() {
+			j := b.column(r.next())
+			if strings.ToLower(r.peek(0)) == "free" {
+				r.next()
+				b.lower[j], b.upper[j] = math.Inf(-1), math.Inf(1)
+				continue
+			}
+			t := r.next()
+			relation := lpRelation(t)
+			if relation == 0 {
+				return r.fail("expected a relation, found %q", t)
+			}
+			x, e := r.signedNumber()
+			if e != nil {
+				return e
+			}
+			setColumnBound(b, j, relation, x)
+			continue
+		}
+
+		x, e := r.signedNumber()
+		if e != nil {
+			return e
+		}
+		t := r.next()
+		relation := lpRelation(t)
+		if relation == 0 {
+			return r.fail("expected a relation, found %q", t)
+		}
+		t = r.next()
+		if t == "" || isNumberToken(t) || isRelation(t) {
+			return r.fail("expected a column, found %q", t)
+		}
+		j := b.column(t)
+		// `value <= x` is `x >= value`.
+		switch relation {
+		case LE:
+			setColumnBound(b, j, GE, x)
+		case GE:
+			setColumnBound(b, j, LE, x)
+		default:
+			setColumnBound(b, j, EQ, x)
+		}
+
+		if isRelation(r.peek(0)) {
+			relation = lpRelation(r.next())
+			x, e := r.signedNumber()
+			if e != nil {
+				return e
+			}
+			setColumnBound(b, j, relation, x)
+		}
+	}
+
+	return nil
+}
+
+// setColumnBound applies `x relation value` to column j.
+func setColumnBound(b *builder, j int, relation Relation, value float64) {
+	switch relation {
+	case LE:
+		b.upper[j] = value
+	case GE:
+		b.lower[j] = value
+	default:
+		b.lower[j], b.upper[j] = value, value
+	}
+}
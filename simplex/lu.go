@@ -0,0 +1,404 @@
+package simplex
+
+import "math"
+
+// refactorInterval is the number of basis updates after which the basis is
+// factorized from scratch.
+const refactorInterval = 100
+
+// markowitzThreshold is how small a pivot of the factorization may be next to
+// the largest entry of its column.
+const markowitzThreshold = 0.1
+
+// markowitzColumns is the number of columns searched for a pivot of the
+// factorization.
+const markowitzColumns = 4
+
+// eta is the update of the basis that replaced the column basic in row with a
+// column whose representation in the previous basis was alpha. Only nonzero
+// entries of alpha are kept.
+type eta struct {
+	row   int
+	pivot float64
+	index []int
+	value []float64
+}
+
+// factorization represents the inverse of a basis B as the LU factorization
+// `P·B0·Q = L·U` of an earlier basis followed by the product form of the
+// updates since: `B = B0·E1·…·Ek`. L and U are kept by columns, only their
+// nonzero entries, as the basis is mostly made of unit columns.
+//
+// The factorization is sparse Gaussian elimination in the order of
+// Markowitz: each pivot is the entry of the remaining matrix with the fewest
+// other entries in its row times in its column, among entries no smaller
+// than markowitzThreshold times the largest of their column. Unit and other
+// short columns are eliminated with little or no fill, so the cost follows
+// the nonzeros of the basis rather than the cube of its rows, which in a week
+// of diets are several hundred.
+type factorization struct {
+	n           int
+	perm        []int
+	columnOrder []int
+
+	lower    sparseMatrix
+	upper    sparseMatrix
+	diagonal []float64
+
+	etas []eta
+}
+
+// factorize computes the factorization of the basis with given columns.
+// It returns false when the basis is singular, every entry left to pivot on
+// no larger than tolerance.
+func (f *factorization) factorize(columns sparseMatrix, tolerance float64) bool {
+
+	n := columns.nColumns()
+
+	f.n = n
+	f.etas = f.etas[:0]
+	f.perm = make([]int, 0, n)
+	f.columnOrder = make([]int, 0, n)
+	f.diagonal = make([]float64, n)
+
+	a := newRemaining(columns)
+
+	// Entries of L by the row they are in and of U by the column, both
+	// numbered in elimination order once every pivot is known.
+	lower := make([][]entry, n)
+	upper := make([][]entry, n)
+
+	for k := 0; k < n; k++ {
+
+		p, q := a.pivot(tolerance)
+		if q == -1 {
+			return false
+		}
+
+		f.perm = append(f.perm, p)
+		f.columnOrder = append(f.columnOrder, q)
+
+		pivot := a.values[q][position(a.entries[q], p)]
+		f.diagonal[k] = pivot
+
+		// Multipliers of the rows below the pivot, the pivot column leaves
+		// their rows.
+		var multipliers []entry
+		for l, i := range a.entries[q] {
+			a.rows[i] = remove(a.rows[i], q)
+			if i != p {
+				m := a.values[q][l] / pivot
+				multipliers = append(multipliers, entry{i, m})
+				lower[i] = append(lower[i], entry{k, m})
+			}
+		}
+		a.drop(q)
+
+		// The pivot row leaves every column and is subtracted from the rows
+		// below it.
+		for _, j := range a.rows[p] {
+			upper[j] = append(upper[j], entry{k, a.take(j, p)})
+			a.subtract(j, multipliers, upper[j][len(upper[j])-1].value)
+		}
+		a.rows[p] = nil
+	}
+
+	// Rows of L and columns of U numbered by original rows and columns are
+	// renumbered to their places in the order of elimination.
+	row := make([]int, n)
+	for k, i := range f.perm {
+		row[i] = k
+	}
+
+	byColumn := make([][]entry, n)
+	for i, es := range lower {
+		for _, e := range es {
+			byColumn[e.index] = append(byColumn[e.index], entry{row[i], e.value})
+		}
+	}
+
+	f.lower = newSparseMatrix(n)
+	f.upper = newSparseMatrix(n)
+	var index []int
+	var value []float64
+
+	for k := 0; k < n; k++ {
+		index, value = index[:0], value[:0]
+		for _, e := range byColumn[k] {
+			index = append(index, e.index)
+			value = append(value, e.value)
+		}
+		f.lower.appendColumn(index, value)
+
+		index, value = index[:0], value[:0]
+		for _, e := range upper[f.columnOrder[k]] {
+			index = append(index, e.index)
+			value = append(value, e.value)
+		}
+		f.upper.appendColumn(index, value)
+	}
+
+	return true
+}
+
+// entry is an entry of a sparse row or column.
+type entry struct {
+	index int
+	value float64
+}
+
+// remaining is the matrix left to eliminate during a factorization. It is
+// kept by columns with values and by rows with the columns of their entries
+// only. Columns not yet eliminated are kept in lists by their numbers of
+// entries, column j at place[j] of its list.
+type remaining struct {
+	entries [][]int
+	values  [][]float64
+	rows    [][]int
+
+	byCount [][]int
+	place   []int
+}
+
+func newRemaining(columns sparseMatrix) *remaining {
+
+	n := columns.nColumns()
+
+	a := &remaining{
+		entries: make([][]int, n),
+		values:  make([][]float64, n),
+		rows:    make([][]int, n),
+		byCount: make([][]int, n+1),
+		place:   make([]int, n),
+	}
+
+	for j := 0; j < n; j++ {
+		index, value := columns.column(j)
+		a.entries[j] = append([]int{}, index...)
+		a.values[j] = append([]float64{}, value...)
+		for _, i := range index {
+			a.rows[i] = append(a.rows[i], j)
+		}
+		a.add(j)
+	}
+
+	return a
+}
+
+// add lists column j by its number of entries.
+func (a *remaining) add(j int) {
+	count := len(a.entries[j])
+	if count >= len(a.byCount) {
+		a.byCount = append(a.byCount, make([][]int, count+1-len(a.byCount))...)
+	}
+	a.place[j] = len(a.byCount[count])
+	a.byCount[count] = append(a.byCount[count], j)
+}
+
+// drop removes column j from the list of its number of entries.
+func (a *remaining) drop(j int) {
+	list := a.byCount[len(a.entries[j])]
+	last := list[len(list)-1]
+	list[a.place[j]], a.place[last] = last, a.place[j]
+	a.byCount[len(a.entries[j])] = list[:len(list)-1]
+}
+
+// take removes the entry of column j in row i and returns its value.
+func (a *remaining) take(j int, i int) float64 {
+
+	a.drop(j)
+
+	l := position(a.entries[j], i)
+	value := a.values[j][l]
+	last := len(a.entries[j]) - 1
+	a.entries[j][l], a.values[j][l] = a.entries[j][last], a.values[j][last]
+	a.entries[j], a.values[j] = a.entries[j][:last], a.values[j][:last]
+
+	a.add(j)
+
+	return value
+}
+
+// subtract subtracts u times the multipliers of their rows from column j.
+func (a *remaining) subtract(j int, multipliers []entry, u float64) {
+
+	a.drop(j)
+
+	for _, m := range multipliers {
+		if l := position(a.entries[j], m.index); l >= 0 {
+			a.values[j][l] -= m.value * u
+		} else {
+			a.entries[j] = append(a.entries[j], m.index)
+			a.values[j] = append(a.values[j], -m.value*u)
+			a.rows[m.index] = append(a.rows[m.index], j)
+		}
+	}
+
+	a.add(j)
+}
+
+// pivot chooses the next pivot, its row and column, or returns -1 as its
+// column when every entry left is no larger than tolerance. Columns are
+// searched by their numbers of entries, fewest first, and the search stops at
+// markowitzColumns columns with an entry large enough to pivot on, as columns
+// with more entries rarely offer a pivot with less fill.
+func (a *remaining) pivot(tolerance float64) (int, int) {
+
+	p, q, cost, best := -1, -1, 0, 0.0
+	searched := 0
+
+	for count := 1; count < len(a.byCount); count++ {
+		for _, j := range a.byCount[count] {
+
+			largest := 0.0
+			for _, v := range a.values[j] {
+				if math.Abs(v) > largest {
+					largest = math.Abs(v)
+				}
+			}
+
+			found := false
+			for l, i := range a.entries[j] {
+				v := math.Abs(a.values[j][l])
+				if v <= tolerance || v < markowitzThreshold*largest {
+					continue
+				}
+				found = true
+				c := (len(a.rows[i]) - 1) * (count - 1)
+				if q == -1 || c < cost || (c == cost && v > best) {
+					p, q, cost, best = i, j, c, v
+				}
+			}
+			if found {
+				searched++
+			}
+			if q != -1 && (cost == 0 || searched >= markowitzColumns) {
+				return p, q
+			}
+		}
+	}
+
+	return p, q
+}
+
+// position returns where i is in index, or -1.
+func position(index []int, i int) int {
+	for l, k := range index {
+		if k == i {
+			return l
+		}
+	}
+	return -1
+}
+
+// remove deletes i from index, not keeping the order of the rest.
+func remove(index []int, i int) []int {
+	l := position(index, i)
+	if l < 0 {
+		return index
+	}
+	last := len(index) - 1
+	index[l] = index[last]
+	return index[:last]
+}
+
+// ftran overwrites v with the solution of `B·x = v`.
+func (f *factorization) ftran(v []float64) {
+
+	x := make([]float64, f.n)
+	for i, p := range f.perm {
+		x[i] = v[p]
+	}
+
+	for j := 0; j < f.n; j++ {
+		if x[j] == 0.0 {
+			continue
+		}
+		rows, values := f.lower.column(j)
+		for k, i := range rows {
+			x[i] -= values[k] * x[j]
+		}
+	}
+	for j := f.n - 1; j >= 0; j-- {
+		if x[j] == 0.0 {
+			continue
+		}
+		x[j] /= f.diagonal[j]
+		rows, values := f.upper.column(j)
+		for k, i := range rows {
+			x[i] -= values[k] * x[j]
+		}
+	}
+
+	for k, j := range f.columnOrder {
+		v[j] = x[k]
+	}
+
+	for _, e := range f.etas {
+		vr := v[e.row] / e.pivot
+		if vr == 0.0 {
+			continue
+		}
+		for k, i := range e.index {
+			v[i] -= e.value[k] * vr
+		}
+		v[e.row] = vr
+	}
+}
+
+// btran overwrites v with the solution of `Bᵀ·y = v`.
+func (f *factorization) btran(v []float64) {
+
+	for k := len(f.etas) - 1; k >= 0; k-- {
+		e := f.etas[k]
+		s := v[e.row]
+		for l, i := range e.index {
+			s -= e.value[l] * v[i]
+		}
+		v[e.row] = s / e.pivot
+	}
+
+	w := make([]float64, f.n)
+	for k, j := range f.columnOrder {
+		w[k] = v[j]
+	}
+
+	for j := 0; j < f.n; j++ {
+		rows, values := f.upper.column(j)
+		for k, i := range rows {
+			w[j] -= values[k] * w[i]
+		}
+		w[j] /= f.diagonal[j]
+	}
+	for j := f.n - 1; j >= 0; j-- {
+		rows, values := f.lower.column(j)
+		for k, i := range rows {
+			w[j] -= values[k] * w[i]
+		}
+	}
+
+	for i, p := range f.perm {
+		v[p] = w[i]
+	}
+}
+
+// update records that the column basic in row was replaced by a column whose
+// ftran is alpha.
+func (f *factorization) update(row int, alpha []float64) {
+
+	e := eta{row: row, pivot: alpha[row]}
+
+	for i, a := range alpha {
+		if i != row && a != 0.0 {
+			e.index = append(e.index, i)
+			e.value = append(e.value, a)
+		}
+	}
+
+	f.etas = append(f.etas, e)
+}
+
+// stale reports whether the factorization should be computed from scratch.
+func (f *factorization) stale() bool {
+	return len(f.etas) >= refactorInterval
+}
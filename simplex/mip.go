@@ -0,0 +1,454 @@
+package simplex
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+const (
+	integralityTolerance = 1e-6
+	defaultGapTolerance  = 1e-6
+	pseudocostMinimum    = 1e-6
+)
+
+// node is a subproblem of branch-and-bound: the problem with tightened bounds
+// on integer variables. Bound is the internal objective of its parent, which
+// nothing in the node can improve on, and start is the basis of the parent
+// the node is solved from. Branch is the variable the parent branched on to
+// make the node, moved up or down by distance, -1 at the root.
+type node struct {
+	lower []float64
+	upper []float64
+	bound float64
+	start *start
+
+	branch   int
+	up       bool
+	distance float64
+}
+
+// branchAndBound solves p with its integer variables kept integral. The
+// search dives depth first, the child that raises the lower bound of its
+// branching variable first, so an incumbent is found early to prune the rest
+// of the tree with. For a binary that is the child that fixes it at one, which
+// in a choice of some items out of many settles the choice quickly, where
+// fixing items at zero one by one rarely does. When a dive ends the search
+// goes on from the node with the lowest bound, so that the bound rises and the
+// gap can close even when the incumbent is near optimal from the start.
+// Variables are branched on by their pseudocosts. Children are solved from
+// the basis of their parent.
+func branchAndBound(ctx context.Context, p problem, options Options) (Result, error) {
+
+	if e := options.check(); e != nil {
+		return Result{}, e
+	}
+
+	gapTolerance := options.GapTolerance
+	if gapTolerance == 0.0 {
+		gapTolerance = defaultGapTolerance
+	}
+
+	var deadline time.Time
+	if options.TimeLimit > 0 {
+		deadline = time.Now().Add(options.TimeLimit)
+	}
+
+	// The search minimizes internally, a maximized objective is negated.
+	sign := 1.0
+	if p.sense == Maximization {
+		sign = -1.0
+	}
+
+	root := node{
+		lower:  make([]float64, len(p.lower)),
+		upper:  make([]float64, len(p.upper)),
+		bound:  math.Inf(-1),
+		branch: -1,
+	}
+	for j := range p.lower {
+		root.lower[j], root.upper[j] = p.lower[j], p.upper[j]
+		if p.integer[j] {
+			root.lower[j] = math.Ceil(root.lower[j] - integralityTolerance)
+			root.upper[j] = math.Floor(root.upper[j] + integralityTolerance)
+			if root.lower[j] > root.upper[j] {
+				return Result{Status: Infeasible}, nil
+			}
+		}
+	}
+
+	result := Result{}
+	incumbent := math.Inf(1)
+
+	if options.Start != nil {
+		if len(options.Start) != len(p.objective) {
+			return Result{}, ErrOptions
+		}
+		if isSolution(p, options.Start, options.feasibilityTolerance()) {
+			result.Values = roundIntegers(options.Start, p.integer)
+			for j, c := range p.objective {
+				result.Objective += c * result.Values[j]
+			}
+			incumbent = sign * result.Objective
+		}
+	}
+	// lost is the lowest bound of nodes dropped unexplored by a limit.
+	lost := math.Inf(1)
+	limited := false
+
+	tolerance := func() float64 {
+		if math.IsInf(incumbent, 1) {
+			return 0.0
+		}
+		return gapTolerance * math.Max(1.0, math.Abs(incumbent))
+	}
+
+	stack := []node{root}
+	dive := false
+	costs := newPseudocosts(len(p.objective))
+
+	for len(stack) > 0 {
+
+		bound := lost
+		for _, n := range stack {
+			bound = math.Min(bound, n.bound)
+		}
+		if incumbent-bound <= tolerance() {
+			break
+		}
+
+		if (options.NodeLimit > 0 && result.Nodes >= options.NodeLimit) ||
+			(!deadline.IsZero() && !time.Now().Before(deadline)) || ctx.Err() != nil {
+			limited = true
+			break
+		}
+
+		// A dive goes on with the last child, when it ends the search goes
+		// back to the node with the lowest bound.
+		k := len(stack) - 1
+		if !dive {
+			for l := range stack {
+				if stack[l].bound < stack[k].bound {
+					k = l
+				}
+			}
+		}
+		n := stack[k]
+		stack = append(stack[:k], stack[k+1:]...)
+		dive = false
+
+		if n.bound >= incumbent-tolerance() {
+			continue
+		}
+
+		relaxation := p
+		relaxation.lower, relaxation.upper = n.lower, n.upper
+
+		lpOptions := options
+		if !deadline.IsZero() {
+			lpOptions.TimeLimit = time.Until(deadline)
+			if lpOptions.TimeLimit <= 0 {
+				lpOptions.TimeLimit = time.Nanosecond
+			}
+		}
+
+		r, t, e := relax(ctx, relaxation, lpOptions, n.start)
+		result.Nodes++
+		result.Iterations += r.Iterations
+		if e != nil && ctx.Err() == nil {
+			return result, e
+		}
+
+		switch r.Status {
+		case Infeasible:
+			continue
+		case Unbounded:
+			return unboundedOrInfeasible(ctx, p, options, result)
+		case LimitReached:
+			lost = math.Min(lost, n.bound)
+			continue
+		}
+
+		value := sign * r.Objective
+		if n.branch >= 0 {
+			costs.record(n.branch, n.up, n.distance, value-n.bound)
+		}
+		if value >= incumbent-tolerance() {
+			continue
+		}
+
+		branch := costs.branch(r.Values, p.integer)
+
+		if branch == -1 {
+			incumbent = value
+			result.Values = roundIntegers(r.Values, p.integer)
+			result.Objective = 0.0
+			for j, c := range p.objective {
+				result.Objective += c * result.Values[j]
+			}
+			if options.Incumbent != nil && !options.Incumbent(result.Objective, append([]float64{}, result.Values...)) {
+				limited = true
+				break
+			}
+			continue
+		}
+
+		x := r.Values[branch]
+		s := t.snapshot()
+
+		down := node{lower: n.lower, upper: append([]float64{}, n.upper...), bound: value, start: s,
+			branch: branch, distance: x - math.Floor(x)}
+		down.upper[branch] = math.Floor(x)
+
+		up := node{lower: append([]float64{}, n.lower...), upper: n.upper, bound: value, start: s,
+			branch: branch, up: true, distance: math.Ceil(x) - x}
+		up.lower[branch] = math.Ceil(x)
+
+		stack = append(stack, down, up)
+		dive = true
+	}
+
+	bound := math.Min(lost, incumbent)
+	for _, n := range stack {
+		bound = math.Min(bound, n.bound)
+	}
+
+	switch {
+	case math.IsInf(incumbent, 1) && (limited || !math.IsInf(lost, 1)):
+		result.Status = LimitReached
+	case math.IsInf(incumbent, 1):
+		result.Status = Infeasible
+	case incumbent-bound <= tolerance():
+		result.Status = Optimal
+	default:
+		result.Status = LimitReached
+	}
+
+	if !math.IsInf(bound, 0) {
+		result.Bound = sign * bound
+	}
+
+	return result, ctx.Err()
+}
+
+// relax solves the relaxation p of a node, from the basis parent when there is
+// one, and returns the solver that holds its optimal basis.
+func relax(ctx context.Context, p problem, options Options, parent *start) (Result, *solver, error) {
+
+	iterations := 0
+
+	if parent != nil {
+		t := parent.solver
+		t.configure(ctx, options)
+
+		status, ok := t.reoptimize(parent, p.lower, p.upper)
+		iterations = t.iterations
+		if ok && status != Optimal {
+			return Result{Status: status, Iterations: iterations}, nil, nil
+		}
+		if ok {
+			values, objective, e := t.point(p)
+			if e == nil {
+				return Result{Status: status, Objective: objective, Values: values, Iterations: iterations}, t, nil
+			}
+		}
+	}
+
+	// Solve from scratch when there is no basis to start from or it failed.
+	var t *solver
+	if options.method() == InteriorPoint {
+		var result Result
+		var e error
+		t, result, e = interiorStart(ctx, p, options, true)
+		iterations += result.Iterations
+		if t == nil {
+			result.Iterations = iterations
+			return result, nil, e
+		}
+	} else {
+		var e error
+		t, e = newSolver(p)
+		if e != nil {
+			return Result{Iterations: iterations}, nil, e
+		}
+		t.configure(ctx, options)
+	}
+
+	status, feasible, e := t.twoPhase(p)
+	if e != nil {
+		return Result{Iterations: iterations + t.iterations}, nil, e
+	}
+	result := Result{Status: status, Iterations: iterations + t.iterations}
+	if !feasible || status != Optimal {
+		return result, nil, nil
+	}
+
+	result.Values, result.Objective, e = t.point(p)
+
+	return result, t, e
+}
+
+// unboundedOrInfeasible settles a problem whose relaxation is unbounded. With
+// rational data the integer problem is then unbounded as well, unless it has
+// no integer point at all, so it looks for any point.
+func unboundedOrInfeasible(ctx context.Context, p problem, options Options, result Result) (Result, error) {
+
+	p.objective = make([]float64, len(p.objective))
+	options.Incumbent = nil
+
+	// Every point is optimal without an objective. The interior point method
+	// would head for the center of the unbounded relaxation, away from the
+	// integer points the simplex method starts among.
+	options.Method = SimplexMethod
+
+	feasibility, e := branchAndBound(ctx, p, options)
+	result.Nodes += feasibility.Nodes
+	result.Iterations += feasibility.Iterations
+
+	result.Status = feasibility.Status
+	if feasibility.Status == Optimal {
+		result.Status = Unbounded
+	}
+
+	return result, e
+}
+
+// isSolution reports whether values are integral where they have to be and
+// satisfy the bounds and rows of p, up to given feasibility tolerance relative
+// to the size of each bound.
+func isSolution(p problem, values []float64, tolerance float64) bool {
+
+	within := func(x float64, lower float64, upper float64) bool {
+		return x >= lower-tolerance*(1.0+math.Abs(lower)) &&
+			x <= upper+tolerance*(1.0+math.Abs(upper))
+	}
+
+	activities := make([]float64, len(p.rowLower))
+
+	for j, x := range values {
+		if !isFinite(x) || !within(x, p.lower[j], p.upper[j]) {
+			return false
+		}
+		if p.integer[j] && math.Abs(x-math.Round(x)) > integralityTolerance {
+			return false
+		}
+		rows, coefficients := p.matrix.column(j)
+		for k, i := range rows {
+			activities[i] += coefficients[k] * x
+		}
+	}
+
+	for i, activity := range activities {
+		if !within(activity, p.rowLower[i], p.rowUpper[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// pseudocosts are the average increases of the objective per unit of the
+// distance a branch moved a variable by, down and up, over the nodes solved
+// after branching on it.
+type pseudocosts struct {
+	sum   [2][]float64
+	count [2][]int
+}
+
+func newPseudocosts(n int) *pseudocosts {
+	return &pseudocosts{
+		sum:   [2][]float64{make([]float64, n), make([]float64, n)},
+		count: [2][]int{make([]int, n), make([]int, n)},
+	}
+}
+
+func direction(up bool) int {
+	if up {
+		return 1
+	}
+	return 0
+}
+
+// record adds the increase of a node whose branch moved variable j by
+// distance.
+func (c *pseudocosts) record(j int, up bool, distance float64, increase float64) {
+	if distance <= 0.0 || math.IsInf(increase, 0) {
+		return
+	}
+	d := direction(up)
+	c.sum[d][j] += math.Max(increase, 0.0) / distance
+	c.count[d][j]++
+}
+
+// average returns the mean pseudocost in direction d of the variables
+// branched on that way, and one when none has been.
+func (c *pseudocosts) average(d int) float64 {
+
+	sum, count := 0.0, 0
+	for j, n := range c.count[d] {
+		if n > 0 {
+			sum += c.sum[d][j] / float64(n)
+			count++
+		}
+	}
+	if count == 0 {
+		return 1.0
+	}
+
+	return sum / float64(count)
+}
+
+// branch returns the fractional integer variable whose branches are estimated
+// to raise the bound the most, by the product of the increases down and up,
+// or -1 when all of them are integral. Variables not branched on yet are
+// estimated by the average, so before any branch is measured the choice is
+// the most fractional variable.
+func (c *pseudocosts) branch(values []float64, integer []bool) int {
+
+	average := [2]float64{c.average(0), c.average(1)}
+	estimate := func(j int, d int) float64 {
+		if c.count[d][j] == 0 {
+			return average[d]
+		}
+		return c.sum[d][j] / float64(c.count[d][j])
+	}
+
+	branch := -1
+	score := 0.0
+
+	for j, x := range values {
+		if !integer[j] {
+			continue
+		}
+		f := x - math.Floor(x)
+		if f <= integralityTolerance || f >= 1.0-integralityTolerance {
+			continue
+		}
+		down := math.Max(estimate(j, 0)*f, pseudocostMinimum)
+		up := math.Max(estimate(j, 1)*(1.0-f), pseudocostMinimum)
+		if branch == -1 || down*up > score {
+			branch = j
+			score = down * up
+		}
+	}
+
+	return branch
+}
+
+// roundIntegers returns values with integer variables rounded.
+func roundIntegers(values []float64, integer []bool) []float64 {
+
+	rounded := make([]float64, len(values))
+
+	for j, x := range values {
+		if integer[j] {
+			x = math.Round(x)
+			if x == 0.0 {
+				x = 0.0
+			}
+		}
+		rounded[j] = x
+	}
+
+	return rounded
+}
@@ -0,0 +1,125 @@
+package simplex
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// randomMIP returns a problem like randomLP with every variable bounded and
+// some of its variables integer, of a few values each.
+func randomMIP(r *rand.Rand) lp {
+
+	d := randomLP(r, 6, 5)
+
+	d.integer = make([]bool, len(d.objective))
+	for j := range d.objective {
+		if math.IsInf(d.lower[j], -1) {
+			d.lower[j] = -float64(r.Intn(5))
+		}
+		if math.IsInf(d.upper[j], 1) {
+			d.upper[j] = d.lower[j] + float64(1+r.Intn(8))
+		}
+	}
+	for _, j := range r.Perm(len(d.objective))[:1+r.Intn(len(d.objective)-1)] {
+		d.integer[j] = true
+		d.upper[j] = math.Min(d.upper[j], d.lower[j]+3)
+	}
+
+	return d
+}
+
+// exactMIP solves d exactly for every value of its integer variables and
+// returns the status and optimum of the best.
+func exactMIP(t *testing.T, d lp) (Status, float64) {
+	t.Helper()
+
+	m := d.model()
+	start := make([]float64, len(d.objective))
+	status, optimum := Infeasible, 0.0
+
+	var search func(j int)
+	search = func(j int) {
+		if j == len(start) {
+			x, e := SolveExact(context.Background(), m, Options{Start: start})
+			if e != nil {
+				t.Fatal(e)
+			}
+			if x.Status != Optimal {
+				return
+			}
+			objective, _ := x.Objective.Float64()
+			better := objective < optimum
+			if d.sense == Maximization {
+				better = objective > optimum
+			}
+			if status != Optimal || better {
+				status, optimum = Optimal, objective
+			}
+			return
+		}
+		if !d.integer[j] {
+			search(j + 1)
+			return
+		}
+		for x := d.lower[j]; x <= d.upper[j]; x++ {
+			start[j] = x
+			search(j + 1)
+		}
+	}
+	search(0)
+
+	return status, optimum
+}
+
+func TestBranchAndBoundOracle(t *testing.T) {
+
+	r := rand.New(rand.NewSource(2))
+	counts := map[Status]int{}
+
+	for k := 0; k < 200; k++ {
+
+		d := randomMIP(r)
+		status, optimum := exactMIP(t, d)
+		counts[status]++
+		m := d.model()
+
+		for _, method := range []Method{SimplexMethod, InteriorPoint} {
+			t.Run(fmt.Sprint(k, " ", method), func(t *testing.T) {
+
+				s, e := Solve(m, Options{Method: method})
+				if e != nil {
+					t.Fatal(e)
+				}
+				if s.Status != status {
+					t.Fatalf("status %v, exact %v", s.Status, status)
+				}
+				if status != Optimal {
+					return
+				}
+
+				objective := 0.0
+				for j, x := range s.Values {
+					objective += d.objective[j] * x
+					if d.integer[j] && x != math.Round(x) {
+						t.Errorf("x%d = %g is not integral", j, x)
+					}
+				}
+				if !closeTo(s.Objective, optimum, 1e-6) || !closeTo(objective, optimum, 1e-6) {
+					t.Errorf("objective %g at values %g, exact %g", s.Objective, objective, optimum)
+				}
+				if !closeTo(s.Bound, optimum, 1e-6) {
+					t.Errorf("bound %g, exact %g", s.Bound, optimum)
+				}
+			})
+		}
+	}
+
+	for _, status := range []Status{Optimal, Infeasible} {
+		if counts[status] == 0 {
+			t.Errorf("no %v problem among %v", status, counts)
+		}
+	}
+}
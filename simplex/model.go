@@ -0,0 +1,631 @@
+package simplex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Var identifies a variable of a Model.
+type Var int
+
+// Constraint identifies a constraint of a Model.
+type Constraint int
+
+// Relation compares the left hand side of a constraint with its right hand
+// side.
+type Relation int
+
+const (
+	// LE is `<=`.
+	LE Relation = iota + 1
+	// GE is `>=`.
+	GE
+	// EQ is `=`.
+	EQ
+)
+
+func (r Relation) String() string {
+	switch r {
+	case LE:
+		return "<="
+	case GE:
+		return ">="
+	case EQ:
+		return "="
+	}
+	return "?"
+}
+
+// bounds returns the range `lower <= a·x <= upper` equivalent to
+// `a·x r rhs`.
+func (r Relation) bounds(rhs float64) (float64, float64) {
+	switch r {
+	case LE:
+		return math.Inf(-1), rhs
+	case GE:
+		return rhs, math.Inf(1)
+	}
+	return rhs, rhs
+}
+
+// Expr is a linear expression, a coefficient for every variable in it.
+type Expr map[Var]float64
+
+var (
+	// ErrDuplicateName is returned when two variables or two constraints of a
+	// model share a name.
+	ErrDuplicateName = errors.New("simplex: duplicate name")
+	// ErrUnknownVar is returned when an expression refers to a variable that
+	// does not belong to the model.
+	ErrUnknownVar = errors.New("simplex: unknown variable")
+	// ErrUnknownConstraint is returned when a constraint given to a search
+	// does not belong to the model.
+	ErrUnknownConstraint = errors.New("simplex: unknown constraint")
+	// ErrBounds is returned when a lower bound is greater than its upper bound.
+	ErrBounds = errors.New("simplex: lower bound is greater than upper bound")
+)
+
+type variable struct {
+	name    string
+	lower   float64
+	upper   float64
+	integer bool
+}
+
+type row struct {
+	name    string
+	expr    Expr
+	lower   float64
+	upper   float64
+	penalty float64
+}
+
+// Model is a linear program over named variables and constraints.
+type Model struct {
+	variables   []variable
+	constraints []row
+
+	objective Expr
+	sense     Sense
+
+	varNames        map[string]Var
+	constraintNames map[string]Constraint
+
+	e error
+}
+
+// NewModel returns an empty model with a zero objective.
+func NewModel() *Model {
+	return &Model{
+		objective:       Expr{},
+		sense:           Maximization,
+		varNames:        map[string]Var{},
+		constraintNames: map[string]Constraint{},
+	}
+}
+
+func (m *Model) fail(e error) {
+	if m.e == nil {
+		m.e = e
+	}
+}
+
+// AddVar adds a variable bounded by lower and upper, either of which may be
+// infinite. An empty name is replaced with `x<index>`.
+func (m *Model) AddVar(name string, lower float64, upper float64) Var {
+
+	v := Var(len(m.variables))
+
+	if name == "" {
+		name = fmt.Sprintf("x%d", v)
+	}
+
+	if _, ok := m.varNames[name]; ok {
+		m.fail(fmt.Errorf("%w: variable %q", ErrDuplicateName, name))
+	}
+	if math.IsNaN(lower) || math.IsNaN(upper) || math.IsInf(lower, 1) || math.IsInf(upper, -1) {
+		m.fail(fmt.Errorf("%w: variable %q", ErrInvalidValue, name))
+	}
+	if lower > upper {
+		m.fail(fmt.Errorf("%w: variable %q", ErrBounds, name))
+	}
+
+	m.varNames[name] = v
+	m.variables = append(m.variables, variable{name, lower, upper, false})
+
+	return v
+}
+
+// AddIntVar adds a variable that only takes integer values between lower and
+// upper.
+func (m *Model) AddIntVar(name string, lower float64, upper float64) Var {
+	v := m.AddVar(name, lower, upper)
+	m.variables[v].integer = true
+	return v
+}
+
+// AddBinaryVar adds a variable that is either 0 or 1.
+func (m *Model) AddBinaryVar(name string) Var {
+	return m.AddIntVar(name, 0.0, 1.0)
+}
+
+// AddConstraint adds the constraint `expr relation rhs`. An empty name is
+// replaced with `c<index>`. A `<=` constraint with +Inf or a `>=` constraint
+// with -Inf right hand side is accepted and bounds nothing.
+func (m *Model) AddConstraint(name string, expr Expr, relation Relation, rhs float64) Constraint {
+
+	if (!isFinite(rhs) && !isUnbounding(relation, rhs)) || (relation != LE && relation != GE && relation != EQ) {
+		m.fail(fmt.Errorf("%w: constraint %q", ErrInvalidValue, name))
+	}
+
+	lower, upper := relation.bounds(rhs)
+
+	return m.AddRange(name, expr, lower, upper)
+}
+
+// AddRange adds the constraint `lower <= expr <= upper` as a single row.
+// Either bound may be infinite, a constraint with both bounds infinite is kept
+// in the model but never reaches the solver. An empty name is replaced with
+// `c<index>`.
+func (m *Model) AddRange(name string, expr Expr, lower float64, upper float64) Constraint {
+
+	c := Constraint(len(m.constraints))
+
+	if name == "" {
+		name = fmt.Sprintf("c%d", c)
+	}
+
+	if _, ok := m.constraintNames[name]; ok {
+		m.fail(fmt.Errorf("%w: constraint %q", ErrDuplicateName, name))
+	}
+	if math.IsNaN(lower) || math.IsNaN(upper) || math.IsInf(lower, 1) || math.IsInf(upper, -1) {
+		m.fail(fmt.Errorf("%w: constraint %q", ErrInvalidValue, name))
+	}
+	if lower > upper {
+		m.fail(fmt.Errorf("%w: constraint %q", ErrBounds, name))
+	}
+	m.checkExpr(expr)
+
+	m.constraintNames[name] = c
+	m.constraints = append(m.constraints, row{name, copyExpr(expr), lower, upper, 0.0})
+
+	return c
+}
+
+// SetPenalty makes c soft: its bounds may be violated, at penalty per unit
+// the left hand side is outside them added to a minimized objective or taken
+// from a maximized one. The objective of a solution includes the penalties.
+// A zero penalty makes c hard again, and a negative one is an error.
+func (m *Model) SetPenalty(c Constraint, penalty float64) {
+	if c < 0 || int(c) >= len(m.constraints) {
+		m.fail(ErrUnknownConstraint)
+		return
+	}
+	if !isFinite(penalty) || penalty < 0.0 {
+		m.fail(fmt.Errorf("%w: penalty of constraint %q", ErrInvalidValue, m.constraints[c].name))
+		return
+	}
+	m.constraints[c].penalty = penalty
+}
+
+// SetObjective sets the objective to optimize expr in given sense.
+func (m *Model) SetObjective(expr Expr, sense Sense) {
+	m.checkExpr(expr)
+	if sense != Maximization && sense != Minimization {
+		m.fail(fmt.Errorf("%w: objective sense", ErrInvalidValue))
+	}
+	m.objective = copyExpr(expr)
+	m.sense = sense
+}
+
+// Maximize sets the objective to maximize expr.
+func (m *Model) Maximize(expr Expr) {
+	m.SetObjective(expr, Maximization)
+}
+
+// Minimize sets the objective to minimize expr.
+func (m *Model) Minimize(expr Expr) {
+	m.SetObjective(expr, Minimization)
+}
+
+// Sense returns the direction of the objective.
+func (m *Model) Sense() Sense {
+	return m.sense
+}
+
+// VarByName returns the variable with given name.
+func (m *Model) VarByName(name string) (Var, bool) {
+	v, ok := m.varNames[name]
+	return v, ok
+}
+
+// ConstraintByName returns the constraint with given name.
+func (m *Model) ConstraintByName(name string) (Constraint, bool) {
+	c, ok := m.constraintNames[name]
+	return c, ok
+}
+
+// VarName returns the name of v.
+func (m *Model) VarName(v Var) string {
+	return m.variables[v].name
+}
+
+// ConstraintName returns the name of c.
+func (m *Model) ConstraintName(c Constraint) string {
+	return m.constraints[c].name
+}
+
+// IsInteger reports whether v only takes integer values.
+func (m *Model) IsInteger(v Var) bool {
+	return m.variables[v].integer
+}
+
+// VarBounds returns the lower and upper bound of v.
+func (m *Model) VarBounds(v Var) (float64, float64) {
+	return m.variables[v].lower, m.variables[v].upper
+}
+
+// Penalty returns the penalty of violating c, zero when c is not soft.
+func (m *Model) Penalty(c Constraint) float64 {
+	return m.constraints[c].penalty
+}
+
+// ConstraintBounds returns the lower and upper bound of c.
+func (m *Model) ConstraintBounds(c Constraint) (float64, float64) {
+	return m.constraints[c].lower, m.constraints[c].upper
+}
+
+// NumVars returns the number of variables in the model.
+func (m *Model) NumVars() int {
+	return len(m.variables)
+}
+
+// NumConstraints returns the number of constraints in the model.
+func (m *Model) NumConstraints() int {
+	return len(m.constraints)
+}
+
+func (m *Model) checkExpr(expr Expr) {
+	for v, x := range expr {
+		if v < 0 || int(v) >= len(m.variables) {
+			m.fail(ErrUnknownVar)
+		}
+		if !isFinite(x) {
+			m.fail(ErrInvalidValue)
+		}
+	}
+}
+
+func copyExpr(expr Expr) Expr {
+
+	c := make(Expr, len(expr))
+
+	for v, x := range expr {
+		c[v] = x
+	}
+
+	return c
+}
+
+// Solution is the result of solving a model. Values are indexed by Var.
+type Solution struct {
+	Result
+
+	model *Model
+}
+
+// Value returns the value of v.
+func (s *Solution) Value(v Var) float64 {
+	if int(v) >= len(s.Values) {
+		return 0.0
+	}
+	return s.Values[v]
+}
+
+// ValueOf returns the value of the variable with given name.
+func (s *Solution) ValueOf(name string) float64 {
+	v, ok := s.model.VarByName(name)
+	if !ok {
+		return 0.0
+	}
+	return s.Value(v)
+}
+
+// Dual returns the dual value of c.
+func (s *Solution) Dual(c Constraint) float64 {
+	if int(c) >= len(s.Duals) {
+		return 0.0
+	}
+	return s.Duals[c]
+}
+
+// ReducedCost returns the reduced cost of v.
+func (s *Solution) ReducedCost(v Var) float64 {
+	if int(v) >= len(s.ReducedCosts) {
+		return 0.0
+	}
+	return s.ReducedCosts[v]
+}
+
+// ObjectiveRange returns the interval the objective coefficient of v can vary
+// in without changing the optimal basis.
+func (s *Solution) ObjectiveRange(v Var) Range {
+	if int(v) >= len(s.ObjectiveRanges) {
+		return Range{}
+	}
+	return s.ObjectiveRanges[v]
+}
+
+// LowerRange returns the interval the lower bound of c can vary in without
+// changing the optimal basis.
+func (s *Solution) LowerRange(c Constraint) Range {
+	if int(c) >= len(s.LowerRanges) {
+		return Range{}
+	}
+	return s.LowerRanges[c]
+}
+
+// UpperRange returns the interval the upper bound of c can vary in without
+// changing the optimal basis.
+func (s *Solution) UpperRange(c Constraint) Range {
+	if int(c) >= len(s.UpperRanges) {
+		return Range{}
+	}
+	return s.UpperRanges[c]
+}
+
+// Violation returns how far the left hand side of c is above its upper
+// bound, or below its lower bound as a negative amount, and zero when it is
+// within them. Only soft constraints are violated beyond rounding.
+func (s *Solution) Violation(c Constraint) float64 {
+
+	activity := s.Activity(c)
+	r := s.model.constraints[c]
+
+	if activity > r.upper {
+		return activity - r.upper
+	}
+	if activity < r.lower {
+		return activity - r.lower
+	}
+
+	return 0.0
+}
+
+// Activity returns the value of the left hand side of c.
+func (s *Solution) Activity(c Constraint) float64 {
+
+	activity := 0.0
+
+	for v, x := range s.model.constraints[c].expr {
+		activity += x * s.Value(v)
+	}
+
+	return activity
+}
+
+// Named returns the values of all variables keyed by their names.
+func (s *Solution) Named() map[string]float64 {
+
+	values := make(map[string]float64, len(s.Values))
+
+	for i, x := range s.Values {
+		values[s.model.variables[i].name] = x
+	}
+
+	return values
+}
+
+// Solve solves the model with default options. Variable bounds are handled by
+// the solver itself and do not add any rows.
+func (m *Model) Solve() (*Solution, error) {
+	return Solve(m, Options{})
+}
+
+// Solve solves m with given options.
+func Solve(m *Model, options Options) (*Solution, error) {
+	return SolveContext(context.Background(), m, options)
+}
+
+// SolveContext solves m with given options until it is done or ctx is
+// canceled. A canceled solve reports LimitReached with the error of ctx. A
+// model with integer variables is solved by branch-and-bound.
+func SolveContext(ctx context.Context, m *Model, options Options) (*Solution, error) {
+
+	solution := &Solution{model: m}
+
+	if m.e != nil {
+		return solution, m.e
+	}
+
+	p := m.problem()
+
+	result, e := solveProblem(ctx, p, m.elasticOptions(p, options))
+	solution.Result = m.hideElastic(result)
+
+	return solution, e
+}
+
+// elasticOptions returns options with a start extended over the elastic
+// columns of soft constraints in p, and an incumbent callback that does not
+// see them.
+func (m *Model) elasticOptions(p problem, options Options) Options {
+
+	n := len(m.variables)
+	if len(p.objective) == n {
+		return options
+	}
+
+	if len(options.Start) == n {
+		options.Start = m.elasticStart(p, options.Start)
+	}
+	if incumbent := options.Incumbent; incumbent != nil {
+		options.Incumbent = func(objective float64, values []float64) bool {
+			return incumbent(objective, values[:n])
+		}
+	}
+
+	return options
+}
+
+// hideElastic drops the elastic columns of soft constraints and the rows
+// added after the constraints of the model from result.
+func (m *Model) hideElastic(result Result) Result {
+
+	n := len(m.variables)
+	if result.Values != nil {
+		result.Values = result.Values[:n]
+	}
+	if result.ReducedCosts != nil {
+		result.ReducedCosts = result.ReducedCosts[:n]
+	}
+	if result.ObjectiveRanges != nil {
+		result.ObjectiveRanges = result.ObjectiveRanges[:n]
+	}
+
+	nConstraints := len(m.constraints)
+	if len(result.Duals) > nConstraints {
+		result.Duals = result.Duals[:nConstraints]
+	}
+	if len(result.LowerRanges) > nConstraints {
+		result.LowerRanges = result.LowerRanges[:nConstraints]
+		result.UpperRanges = result.UpperRanges[:nConstraints]
+	}
+
+	return result
+}
+
+// solveProblem solves p scaled as options say, see solveScaled.
+func solveProblem(ctx context.Context, p problem, options Options) (Result, error) {
+
+	if options.scaling() == NoScaling {
+		return solveScaled(ctx, p, options)
+	}
+
+	s := newScaling(p)
+	result, e := solveScaled(ctx, s.problem(p), s.options(options))
+
+	return s.result(p, result), e
+}
+
+// solveScaled solves p as it is, by branch-and-bound when it has integer
+// variables and by the simplex method otherwise.
+func solveScaled(ctx context.Context, p problem, options Options) (Result, error) {
+	if p.integer != nil {
+		return branchAndBound(ctx, p, options)
+	}
+	return solve(ctx, p, options)
+}
+
+// problem lowers the model into sparse columns.
+func (m *Model) problem() problem {
+
+	p := problem{
+		sense:        m.sense,
+		objective:    make([]float64, len(m.variables)),
+		nConstraints: len(m.constraints),
+	}
+
+	for v, x := range m.objective {
+		p.objective[v] = x
+	}
+
+	rows := make([][]int, len(m.variables))
+	values := make([][]float64, len(m.variables))
+
+	for c, r := range m.constraints {
+		if math.IsInf(r.lower, -1) && math.IsInf(r.upper, 1) {
+			continue
+		}
+		i := len(p.constraints)
+		for v, x := range r.expr {
+			rows[v] = append(rows[v], i)
+			values[v] = append(values[v], x)
+		}
+		p.rowLower = append(p.rowLower, r.lower)
+		p.rowUpper = append(p.rowUpper, r.upper)
+		p.constraints = append(p.constraints, c)
+	}
+
+	p.matrix = newSparseMatrix(len(p.constraints))
+	for v := range m.variables {
+		p.matrix.appendColumn(rows[v], values[v])
+	}
+
+	for j, v := range m.variables {
+		p.lower = append(p.lower, v.lower)
+		p.upper = append(p.upper, v.upper)
+		if v.integer {
+			if p.integer == nil {
+				p.integer = make([]bool, len(m.variables))
+			}
+			p.integer[j] = true
+		}
+	}
+
+	// A soft row gets an elastic column for every finite bound, that makes
+	// up for the left hand side falling short of the bound or going past it
+	// at the cost of the penalty.
+	cost := 1.0
+	if m.sense == Maximization {
+		cost = -1.0
+	}
+
+	for i, c := range p.constraints {
+		r := m.constraints[c]
+		if r.penalty == 0.0 {
+			continue
+		}
+		if !math.IsInf(r.lower, -1) {
+			p.addElastic(i, 1.0, cost*r.penalty)
+		}
+		if !math.IsInf(r.upper, 1) {
+			p.addElastic(i, -1.0, cost*r.penalty)
+		}
+	}
+
+	return p
+}
+
+// addElastic adds a non-negative column with a single coefficient in row i.
+func (p *problem) addElastic(i int, coefficient float64, cost float64) {
+	p.matrix.appendColumn([]int{i}, []float64{coefficient})
+	p.objective = append(p.objective, cost)
+	p.lower = append(p.lower, 0.0)
+	p.upper = append(p.upper, math.Inf(1))
+	if p.integer != nil {
+		p.integer = append(p.integer, false)
+	}
+}
+
+// elasticStart returns start extended with the values of elastic columns
+// that make it satisfy the soft rows of p. Elastic columns follow the
+// variables of the model, in the order problem adds them.
+func (m *Model) elasticStart(p problem, start []float64) []float64 {
+
+	extended := append([]float64{}, start...)
+
+	for _, c := range p.constraints {
+
+		r := m.constraints[c]
+		if r.penalty == 0.0 {
+			continue
+		}
+
+		activity := 0.0
+		for v, x := range r.expr {
+			activity += x * start[v]
+		}
+
+		if !math.IsInf(r.lower, -1) {
+			extended = append(extended, math.Max(r.lower-activity, 0.0))
+		}
+		if !math.IsInf(r.upper, 1) {
+			extended = append(extended, math.Max(activity-r.upper, 0.0))
+		}
+	}
+
+	return extended
+}
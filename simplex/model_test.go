@@ -0,0 +1,147 @@
+package simplex
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestSoftConstraints(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		sense   Sense
+		hard    bool
+		penalty float64
+
+		objective float64
+		x         float64
+		violation float64
+	}{
+		// x >= 4 costs more to meet than to miss at a penalty under one.
+		{name: "cheap to miss", sense: Minimization, penalty: 0.5, objective: 2, x: 0, violation: -4},
+		{name: "dear to miss", sense: Minimization, penalty: 3, objective: 4, x: 4, violation: 0},
+		// With x <= 2 hard the model is infeasible but for the penalty.
+		{name: "infeasible", sense: Minimization, hard: true, penalty: 3, objective: 8, x: 2, violation: -2},
+		// Penalties are taken from a maximized objective.
+		{name: "maximized infeasible", sense: Maximization, hard: true, penalty: 0.5, objective: 1, x: 2, violation: -2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			m := NewModel()
+			x := m.AddVar("x", 0, 10)
+			if test.hard {
+				m.AddConstraint("most", Expr{x: 1}, LE, 2)
+			}
+			c := m.AddConstraint("least", Expr{x: 1}, GE, 4)
+			m.SetPenalty(c, test.penalty)
+			m.SetObjective(Expr{x: 1}, test.sense)
+
+			s, e := m.Solve()
+			if e != nil {
+				t.Fatal(e)
+			}
+			if s.Status != Optimal {
+				t.Fatalf("status %v", s.Status)
+			}
+			if !near(s.Objective, test.objective) || !near(s.Value(x), test.x) {
+				t.Errorf("objective %g at x = %g, want %g at %g", s.Objective, s.Value(x), test.objective, test.x)
+			}
+			if !near(s.Violation(c), test.violation) {
+				t.Errorf("violation %g, want %g", s.Violation(c), test.violation)
+			}
+		})
+	}
+}
+
+func TestSoftConstraintsHideElastic(t *testing.T) {
+
+	m := NewModel()
+	x := m.AddVar("x", 0, 10)
+	most := m.AddConstraint("most", Expr{x: 1}, LE, 2)
+	least := m.AddRange("least", Expr{x: 1}, 4, 6)
+	m.SetPenalty(least, 3)
+	m.Minimize(Expr{x: 1})
+
+	if n := len(m.problem().objective); n != 3 {
+		t.Fatalf("%d columns, want x and an elastic column per bound of least", n)
+	}
+
+	s, e := m.Solve()
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(s.Values) != 1 || len(s.ReducedCosts) != 1 || len(s.ObjectiveRanges) != 1 {
+		t.Errorf("values %v, reduced costs %v and objective ranges %v of elastic columns", s.Values, s.ReducedCosts, s.ObjectiveRanges)
+	}
+	if len(s.Duals) != 2 || len(s.LowerRanges) != 2 || len(s.UpperRanges) != 2 {
+		t.Errorf("duals %v and ranges %v %v beyond the constraints", s.Duals, s.LowerRanges, s.UpperRanges)
+	}
+	if !near(s.Dual(most), -2) || !near(s.Dual(least), 3) {
+		t.Errorf("duals %v, want [-2 3]", s.Duals)
+	}
+}
+
+func TestElasticStart(t *testing.T) {
+
+	m := NewModel()
+	x := m.AddVar("x", 0, 10)
+	y := m.AddVar("y", 0, 10)
+	m.AddConstraint("hard", Expr{x: 1, y: 1}, LE, 20)
+	below := m.AddRange("below", Expr{x: 1, y: 1}, 4, 6)
+	above := m.AddConstraint("above", Expr{x: 1}, LE, 1)
+	m.SetPenalty(below, 1)
+	m.SetPenalty(above, 1)
+
+	// At x = 2, y = 1 the sum falls 1 short of below and x is 1 past
+	// above.
+	got := m.elasticStart(m.problem(), []float64{2, 1})
+	if want := []float64{2, 1, 1, 0, 1}; !nearAll(got, want) {
+		t.Errorf("start %v, want %v", got, want)
+	}
+}
+
+func TestZeroPenalty(t *testing.T) {
+
+	m := NewModel()
+	x := m.AddVar("x", 0, 10)
+	m.AddConstraint("most", Expr{x: 1}, LE, 2)
+	c := m.AddConstraint("least", Expr{x: 1}, GE, 4)
+	m.SetPenalty(c, 3)
+	m.SetPenalty(c, 0)
+	m.Minimize(Expr{x: 1})
+
+	if m.Penalty(c) != 0 {
+		t.Errorf("penalty %g", m.Penalty(c))
+	}
+	s, e := m.Solve()
+	if e != nil {
+		t.Fatal(e)
+	}
+	if s.Status != Infeasible {
+		t.Errorf("status %v of a hard conflict", s.Status)
+	}
+}
+
+func TestInvalidPenalty(t *testing.T) {
+
+	for _, penalty := range []float64{-1, math.Inf(1), math.NaN()} {
+
+		m := NewModel()
+		x := m.AddVar("x", 0, 10)
+		c := m.AddConstraint("least", Expr{x: 1}, GE, 4)
+		m.SetPenalty(c, penalty)
+
+		if _, e := m.Solve(); !errors.Is(e, ErrInvalidValue) {
+			t.Errorf("penalty %g: error %v, want %v", penalty, e, ErrInvalidValue)
+		}
+	}
+
+	m := NewModel()
+	m.SetPenalty(0, 1)
+	if _, e := m.Solve(); !errors.Is(e, ErrUnknownConstraint) {
+		t.Errorf("error %v, want %v", e, ErrUnknownConstraint)
+	}
+}
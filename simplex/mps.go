@@ -0,0 +1,409 @@
+package simplex
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// WriteMPS writes the model in free MPS format. The objective is the row
+// "obj", and the sense is given by an OBJSENSE section. A ranged constraint is
+// a `G` or an `L` row with its range in the RANGES section, and integer variables are
+// marked with INTORG and INTEND. Soft constraints are written with their
+// elastic columns and constraints that bound nothing are left out, so the
+// file holds the linear program the solver sees. Names are changed where the
+// format does not allow them, see WriteLP.
+func (m *Model) WriteMPS(w io.Writer) error {
+
+	if m.e != nil {
+		return m.e
+	}
+
+	l := m.lower()
+	b := bufio.NewWriter(w)
+
+	fmt.Fprintln(b, "NAME")
+	fmt.Fprintln(b, "OBJSENSE")
+	if l.sense == Maximization {
+		fmt.Fprintln(b, "    MAX")
+	} else {
+		fmt.Fprintln(b, "    MIN")
+	}
+
+	kinds := make([]string, len(l.rows))
+	rhs := make([]float64, len(l.rows))
+	ranges := make([]float64, len(l.rows))
+
+	fmt.Fprintln(b, "ROWS")
+	fmt.Fprintln(b, " N  obj")
+	for i, name := range l.rows {
+		kinds[i], rhs[i], ranges[i] = mpsRow(l.rowLower[i], l.rowUpper[i])
+		fmt.Fprintf(b, " %s  %s\n", kinds[i], name)
+	}
+
+	fmt.Fprintln(b, "COLUMNS")
+	marked := false
+	for j, name := range l.columns {
+		integer := l.integer != nil && l.integer[j]
+		if integer != marked {
+			if integer {
+				fmt.Fprintln(b, "    MARKER  'MARKER'  'INTORG'")
+			} else {
+				fmt.Fprintln(b, "    MARKER  'MARKER'  'INTEND'")
+			}
+			marked = integer
+		}
+		rows, values := l.matrix.column(j)
+		if l.objective[j] != 0.0 || len(rows) == 0 {
+			fmt.Fprintf(b, "    %s  obj  %s\n", name, formatNumber(l.objective[j]))
+		}
+		for k, i := range rows {
+			fmt.Fprintf(b, "    %s  %s  %s\n", name, l.rows[i], formatNumber(values[k]))
+		}
+	}
+	if marked {
+		fmt.Fprintln(b, "    MARKER  'MARKER'  'INTEND'")
+	}
+
+	fmt.Fprintln(b, "RHS")
+	for i, name := range l.rows {
+		if rhs[i] != 0.0 {
+			fmt.Fprintf(b, "    RHS  %s  %s\n", name, formatNumber(rhs[i]))
+		}
+	}
+
+	fmt.Fprintln(b, "RANGES")
+	for i, name := range l.rows {
+		if ranges[i] != 0.0 {
+			fmt.Fprintf(b, "    RNG  %s  %s\n", name, formatNumber(ranges[i]))
+		}
+	}
+
+	fmt.Fprintln(b, "BOUNDS")
+	for j, name := range l.columns {
+		lower, upper := l.lower[j], l.upper[j]
+		switch {
+		case lower == upper:
+			fmt.Fprintf(b, " FX BND  %s  %s\n", name, formatNumber(lower))
+			continue
+		case math.IsInf(lower, -1) && math.IsInf(upper, 1):
+			fmt.Fprintf(b, " FR BND  %s\n", name)
+			continue
+		case math.IsInf(lower, -1):
+			fmt.Fprintf(b, " MI BND  %s\n", name)
+		case lower != 0.0:
+			fmt.Fprintf(b, " LO BND  %s  %s\n", name, formatNumber(lower))
+		}
+		if !math.IsInf(upper, 1) {
+			fmt.Fprintf(b, " UP BND  %s  %s\n", name, formatNumber(upper))
+		} else if l.integer != nil && l.integer[j] {
+			// Some readers take integer columns without bounds as binary.
+			fmt.Fprintf(b, " PL BND  %s\n", name)
+		}
+	}
+
+	fmt.Fprintln(b, "ENDATA")
+
+	return b.Flush()
+}
+
+// mpsRow returns the type, the right hand side and the range of a row bounded
+// by lower and upper, the range zero when the row has none. The range of a
+// ranged row is chosen among the numbers nearest upper - lower so that its
+// bounds are read back exactly, as a `G` row from the lower bound or an `L`
+// row from the upper one, where any of them is.
+func mpsRow(lower float64, upper float64) (string, float64, float64) {
+
+	switch {
+	case lower == upper:
+		return "E", lower, 0.0
+	case math.IsInf(lower, -1):
+		return "L", upper, 0.0
+	case math.IsInf(upper, 1):
+		return "G", lower, 0.0
+	}
+
+	r := upper - lower
+	for _, x := range []float64{r, math.Nextafter(r, math.Inf(1)), math.Nextafter(r, math.Inf(-1))} {
+		if lower+x == upper {
+			return "G", lower, x
+		}
+		if upper-x == lower {
+			return "L", upper, x
+		}
+	}
+
+	return "G", lower, r
+}
+
+// ReadMPS reads a model in free MPS format. The first `N` row is the
+// objective, which is minimized unless an OBJSENSE section says otherwise,
+// and later `N` rows are constraints that bound nothing. Columns are bounded
+// by `0 <= x <= +Inf` unless the BOUNDS section sets their bounds, a negative
+// `UP` bound of a column without a lower bound making it unbounded below. An
+// objective constant given in the RHS section is not supported.
+func ReadMPS(r io.Reader) (*Model, error) {
+
+	b := newBuilder()
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<24)
+
+	section := ""
+	objective := ""
+	integer := false
+	lowered := map[int]bool{}
+	ended := false
+
+	for line := 1; scanner.Scan(); line++ {
+
+		text := scanner.Text()
+		fields := strings.Fields(text)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "*") {
+			continue
+		}
+		if ended {
+			return nil, formatError(line, "data after ENDATA")
+		}
+
+		// Section headers start at the beginning of a line.
+		if text[0] != ' ' && text[0] != '\t' {
+			section = strings.ToUpper(fields[0])
+			switch section {
+			case "NAME", "ROWS", "COLUMNS", "RHS", "RANGES", "BOUNDS":
+			case "OBJSENSE":
+				if len(fields) > 1 {
+					if e := setSense(b, fields[1], line); e != nil {
+						return nil, e
+					}
+				}
+			case "ENDATA":
+				ended = true
+			default:
+				return nil, formatError(line, "unknown section %q", fields[0])
+			}
+			continue
+		}
+
+		switch section {
+
+		case "OBJSENSE":
+			if e := setSense(b, fields[0], line); e != nil {
+				return nil, e
+			}
+
+		case "ROWS":
+			if len(fields) != 2 {
+				return nil, formatError(line, "row needs a type and a name")
+			}
+			kind, name := strings.ToUpper(fields[0]), fields[1]
+			if kind == "N" && objective == "" {
+				objective = name
+				continue
+			}
+			if name == objective {
+				return nil, formatError(line, "row %q is the objective", name)
+			}
+			i, ok := b.row(name)
+			if !ok {
+				return nil, formatError(line, "duplicate row %q", name)
+			}
+			switch kind {
+			case "E":
+				b.rowLower[i], b.rowUpper[i] = 0.0, 0.0
+			case "L":
+				b.rowUpper[i] = 0.0
+			case "G":
+				b.rowLower[i] = 0.0
+			case "N":
+			default:
+				return nil, formatError(line, "unknown row type %q", fields[0])
+			}
+
+		case "COLUMNS":
+			if len(fields) == 3 && strings.Trim(fields[1], "'") == "MARKER" {
+				switch strings.Trim(fields[2], "'") {
+				case "INTORG":
+					integer = true
+				case "INTEND":
+					integer = false
+				default:
+					return nil, formatError(line, "unknown marker %q", fields[2])
+				}
+				continue
+			}
+			if len(fields) != 3 && len(fields) != 5 {
+				return nil, formatError(line, "column needs a name and pairs of rows and values")
+			}
+			j := b.column(fields[0])
+			b.integer[j] = b.integer[j] || integer
+			for k := 1; k < len(fields); k += 2 {
+				x, ok := parseNumber(fields[k+1])
+				if !ok {
+					return nil, formatError(line, "invalid value %q", fields[k+1])
+				}
+				if fields[k] == objective {
+					b.objective[j] = x
+					continue
+				}
+				i, ok := b.rowIndex[fields[k]]
+				if !ok {
+					return nil, formatError(line, "unknown row %q", fields[k])
+				}
+				b.exprs[i][j] = x
+			}
+
+		case "RHS", "RANGES":
+			// The set name is optional when a pair follows.
+			if len(fields)%2 == 1 {
+				fields = fields[1:]
+			}
+			if len(fields) == 0 {
+				return nil, formatError(line, "%s needs pairs of rows and values", section)
+			}
+			for k := 0; k < len(fields); k += 2 {
+				x, ok := parseNumber(fields[k+1])
+				if !ok {
+					return nil, formatError(line, "invalid value %q", fields[k+1])
+				}
+				if fields[k] == objective {
+					return nil, formatError(line, "objective constant is not supported")
+				}
+				i, ok := b.rowIndex[fields[k]]
+				if !ok {
+					return nil, formatError(line, "unknown row %q", fields[k])
+				}
+				if section == "RHS" {
+					setRHS(b, i, x)
+				} else {
+					setRange(b, i, x)
+				}
+			}
+
+		case "BOUNDS":
+			if e := setBound(b, fields, lowered, line); e != nil {
+				return nil, e
+			}
+
+		default:
+			return nil, formatError(line, "data outside of a section")
+		}
+	}
+
+	if e := scanner.Err(); e != nil {
+		return nil, e
+	}
+	if !ended {
+		return nil, fmt.Errorf("%w: no ENDATA", ErrFormat)
+	}
+
+	return b.model()
+}
+
+// setSense sets the sense of the objective from an OBJSENSE value.
+func setSense(b *builder, value string, line int) error {
+	switch strings.ToUpper(value) {
+	case "MAX", "MAXIMIZE":
+		b.sense = Maximization
+	case "MIN", "MINIMIZE":
+		b.sense = Minimization
+	default:
+		return formatError(line, "unknown objective sense %q", value)
+	}
+	return nil
+}
+
+// setRHS moves the finite bounds of row i to rhs. The bounds are still both
+// zero or infinite, as ranges come after the right hand sides.
+func setRHS(b *builder, i int, rhs float64) {
+	if !math.IsInf(b.rowLower[i], -1) {
+		b.rowLower[i] = rhs
+	}
+	if !math.IsInf(b.rowUpper[i], 1) {
+		b.rowUpper[i] = rhs
+	}
+}
+
+// setRange widens row i by the range r. An `E` row extends above its right
+// hand side when r is positive and below it otherwise.
+func setRange(b *builder, i int, r float64) {
+	lower, upper := b.rowLower[i], b.rowUpper[i]
+	switch {
+	case lower == upper && r >= 0.0:
+		b.rowUpper[i] = lower + r
+	case lower == upper:
+		b.rowLower[i] = upper + r
+	case math.IsInf(lower, -1):
+		b.rowLower[i] = upper - math.Abs(r)
+	case math.IsInf(upper, 1):
+		b.rowUpper[i] = lower + math.Abs(r)
+	}
+}
+
+// setBound applies a line of the BOUNDS section. Lowered records the columns
+// whose lower bound was set, which a negative `UP` bound leaves alone.
+func setBound(b *builder, fields []string, lowered map[int]bool, line int) error {
+
+	kind := strings.ToUpper(fields[0])
+
+	needsValue := true
+	switch kind {
+	case "FR", "MI", "PL", "BV":
+		needsValue = false
+	}
+
+	// The bound set name is optional.
+	switch {
+	case needsValue && len(fields) == 4, !needsValue && len(fields) == 3:
+		fields = fields[2:]
+	case needsValue && len(fields) == 3, !needsValue && len(fields) == 2:
+		fields = fields[1:]
+	default:
+		return formatError(line, "bound needs a type, a column and a value")
+	}
+
+	j := b.column(fields[0])
+
+	x := 0.0
+	if needsValue {
+		var ok bool
+		x, ok = parseNumber(fields[1])
+		if !ok {
+			return formatError(line, "invalid value %q", fields[1])
+		}
+	}
+
+	switch kind {
+	case "UP", "UI":
+		b.upper[j] = x
+		if x < 0.0 && b.lower[j] == 0.0 && !lowered[j] {
+			b.lower[j] = math.Inf(-1)
+		}
+	case "LO", "LI":
+		b.lower[j] = x
+		lowered[j] = true
+	case "FX":
+		b.lower[j], b.upper[j] = x, x
+		lowered[j] = true
+	case "FR":
+		b.lower[j], b.upper[j] = math.Inf(-1), math.Inf(1)
+		lowered[j] = true
+	case "MI":
+		b.lower[j] = math.Inf(-1)
+		lowered[j] = true
+	case "PL":
+		b.upper[j] = math.Inf(1)
+	case "BV":
+		b.lower[j], b.upper[j] = 0.0, 1.0
+		lowered[j] = true
+	default:
+		return formatError(line, "unknown bound type %q", fields[0])
+	}
+
+	if kind == "UI" || kind == "LI" || kind == "BV" {
+		b.integer[j] = true
+	}
+
+	return nil
+}
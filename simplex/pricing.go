@@ -0,0 +1,118 @@
+package simplex
+
+import "math"
+
+// degenerateLimit is the number of consecutive pivots that leave the objective
+// unchanged after which pricing falls back to Bland's rule.
+const degenerateLimit = 50
+
+// Pricing chooses the column that enters the basis.
+type Pricing int
+
+const (
+	// Dantzig enters the column with the largest reduced cost.
+	Dantzig Pricing = iota + 1
+	// Bland enters the first column that improves the objective. It is slow,
+	// but never cycles.
+	Bland
+	// SteepestEdge enters the column with the largest reduced cost relative
+	// to the length of its edge, which usually takes the fewest pivots. Edge
+	// lengths are approximated with devex reference weights.
+	SteepestEdge
+)
+
+func (p Pricing) String() string {
+	switch p {
+	case Dantzig:
+		return "dantzig"
+	case Bland:
+		return "bland"
+	case SteepestEdge:
+		return "steepest edge"
+	}
+	return "unknown"
+}
+
+// entering returns the column that enters the basis among the first nColumns
+// to improve the current costs, and the direction it moves in: 1 up from its
+// lower bound, -1 down from its upper bound. The column is -1 at optimality.
+func (t *solver) entering(nColumns int) (int, float64) {
+
+	pricing := t.pricing
+	if t.bland() {
+		pricing = Bland
+	}
+
+	best, bestDirection, bestScore := -1, 0.0, 0.0
+
+	for j := 0; j < nColumns; j++ {
+
+		if t.basic[j] {
+			continue
+		}
+
+		d := t.reduced[j]
+
+		direction := 0.0
+		if d < -t.optimalityTolerance && t.x[j] < t.upper[j] {
+			direction = 1.0
+		} else if d > t.optimalityTolerance && t.x[j] > t.lower[j] {
+			direction = -1.0
+		} else {
+			continue
+		}
+
+		if pricing == Bland {
+			return j, direction
+		}
+
+		score := d * d
+		if pricing == SteepestEdge {
+			score /= t.weights[j]
+		}
+
+		if score > bestScore {
+			best, bestDirection, bestScore = j, direction, score
+		}
+	}
+
+	return best, bestDirection
+}
+
+// updateWeights updates the reference weights of the devex approximation of
+// steepest edge pricing before column q with representation alpha becomes
+// basic in given row. The weight of a column approximates the squared length
+// of its edge.
+func (t *solver) updateWeights(row int, q int, alpha []float64) {
+
+	rho := make([]float64, t.nRows)
+	rho[row] = 1.0
+	t.factor.btran(rho)
+
+	pivot := alpha[row]
+	weight := t.weights[q]
+
+	for j := 0; j < t.nColumns; j++ {
+		if t.basic[j] || j == q {
+			continue
+		}
+		a := t.dot(j, rho) / pivot
+		t.weights[j] = math.Max(t.weights[j], a*a*weight)
+	}
+
+	t.weights[t.basis[row]] = math.Max(weight/(pivot*pivot), 1.0)
+}
+
+// bland reports whether Bland's rule is in effect.
+func (t *solver) bland() bool {
+	return t.pricing == Bland || t.degenerate >= degenerateLimit
+}
+
+// countDegenerate tracks the run of pivots with zero step.
+func (t *solver) countDegenerate(step float64) {
+	if step <= t.feasibilityTolerance {
+		t.degenerate++
+	} else {
+		t.degenerate = 0
+	}
+}
@@ -0,0 +1,50 @@
+package simplex
+
+import "math"
+
+// problem is a linear program in the form solved by the simplex method:
+// optimize objective·x subject to `rowLower <= A·x <= rowUpper` and
+// `lower <= x <= upper`, where any bound may be infinite and A is stored by
+// columns. Row i comes from the caller's constraint constraints[i], out of
+// nConstraints, so that rows the caller had dropped still get a dual. Integer
+// is nil when no variable has to be integral.
+type problem struct {
+	sense     Sense
+	objective []float64
+
+	matrix   sparseMatrix
+	rowLower []float64
+	rowUpper []float64
+
+	constraints  []int
+	nConstraints int
+
+	lower   []float64
+	upper   []float64
+	integer []bool
+}
+
+// logicalBounds returns the right hand side and the bounds of the logical
+// column s of a row `a·x + s = rhs` that make it equivalent to
+// `lower <= a·x <= upper`.
+func logicalBounds(lower float64, upper float64) (float64, float64, float64) {
+	if !math.IsInf(upper, 1) {
+		return upper, 0.0, upper - lower
+	}
+	if !math.IsInf(lower, -1) {
+		return lower, math.Inf(-1), 0.0
+	}
+	return 0.0, math.Inf(-1), math.Inf(1)
+}
+
+// startingValue places a nonbasic variable at one of its bounds, or at zero
+// when it is free.
+func startingValue(lower float64, upper float64) float64 {
+	if !math.IsInf(lower, -1) {
+		return lower
+	}
+	if !math.IsInf(upper, 1) {
+		return upper
+	}
+	return 0.0
+}
@@ -0,0 +1,196 @@
+package simplex
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// Square is a term of a quadratic objective: Weight times the square of how
+// far Expr is from Target.
+type Square struct {
+	Expr   Expr
+	Target float64
+	Weight float64
+}
+
+// SolveQuadratic optimizes the objective of m together with squares, whose
+// sum is added to a minimized objective and taken from a maximized one, so
+// the problem stays convex. It suits objectives such as staying close to the
+// middle of ranges, which a linear program cannot express as its optimal
+// points lie on vertices.
+//
+// The problem is solved by the interior point method, every square a free
+// column `Expr - Target` of a new row with its weight on the diagonal of the
+// objective. The method already handles the bounds and ranged rows of the
+// linear problem, and a diagonal quadratic only adds to the diagonal of its
+// normal equations, so it converges in as few iterations as on a linear
+// program, where an active-set method would pivot through every face the
+// optimum is not on and ADMM would converge to low accuracy only. The
+// problem is scaled as Scaling says and MaxIterations limits the iterations
+// of the method. Method, Crossover and the options of branch-and-bound do
+// not apply. A model with integer variables needs Start,
+// a solution whose values of integer variables are kept while the rest is
+// optimized.
+//
+// The result reports Objective with the squares, and Duals and ReducedCosts
+// at the optimum, but no ranges. An infeasible problem is reported as such,
+// as is an unbounded one, which a problem that a square bounds in every
+// direction never is.
+func SolveQuadratic(ctx context.Context, m *Model, squares []Square, options Options) (*Solution, error) {
+
+	solution := &Solution{model: m}
+
+	if m.e != nil {
+		return solution, m.e
+	}
+	if e := options.check(); e != nil {
+		return solution, e
+	}
+	for k, s := range squares {
+		if e := m.checkSquare(s); e != nil {
+			return solution, fmt.Errorf("%w: square %d", e, k+1)
+		}
+	}
+
+	p := m.problem()
+	n := len(m.variables)
+
+	var start []float64
+	if p.integer != nil {
+		if len(options.Start) != n {
+			return solution, fmt.Errorf("%w: integer variables need a start", ErrOptions)
+		}
+		start = options.Start
+	}
+
+	result, e := solveQuadratic(ctx, p, squares, start, options)
+	solution.Result = m.hideElastic(result)
+
+	return solution, e
+}
+
+// solveQuadratic solves p with squares added to its objective, integer
+// columns fixed at their values in start, see SolveQuadratic.
+func solveQuadratic(ctx context.Context, p problem, squares []Square, start []float64, options Options) (Result, error) {
+
+	p, e := fixIntegers(p, start)
+	if e != nil {
+		return Result{}, e
+	}
+
+	// Every square gets a row `Expr - r = Target` and its free column r.
+	p.objective = append([]float64{}, p.objective...)
+	p.rowLower = append([]float64{}, p.rowLower...)
+	p.rowUpper = append([]float64{}, p.rowUpper...)
+	p.constraints = append([]int{}, p.constraints...)
+
+	for _, s := range squares {
+		values := make([]float64, len(p.objective))
+		for v, x := range s.Expr {
+			values[v] = x
+		}
+		p.matrix.appendRow(values)
+		p.rowLower = append(p.rowLower, s.Target)
+		p.rowUpper = append(p.rowUpper, s.Target)
+		p.constraints = append(p.constraints, p.nConstraints)
+		p.nConstraints++
+	}
+
+	first := len(p.objective)
+	for k := range squares {
+		p.matrix.appendColumn([]int{len(p.rowLower) - len(squares) + k}, []float64{-1.0})
+		p.objective = append(p.objective, 0.0)
+		p.lower = append(p.lower, math.Inf(-1))
+		p.upper = append(p.upper, math.Inf(1))
+	}
+
+	// The problem is scaled as solveProblem scales it. A square of the
+	// column r is a square of r/c on the scaled column, times c².
+	scaling := unitScaling(p)
+	if options.scaling() != NoScaling {
+		scaling = newScaling(p)
+	}
+	scaled := scaling.problem(p)
+
+	ip := newInterior(scaled)
+	ip.quadratic = make([]float64, len(ip.z))
+	for k, s := range squares {
+		c := scaling.columns[first+k]
+		ip.quadratic[first+k] = 2.0 * s.Weight * c * c
+	}
+
+	switch ip.solve(ctx, options) {
+	case Optimal:
+		result := scaling.result(p, ip.result(scaled))
+		result.Values = result.Values[:first]
+		result.ReducedCosts = result.ReducedCosts[:first]
+		return result, nil
+	case LimitReached:
+		return Result{Status: LimitReached, Iterations: ip.iterations}, ctx.Err()
+	}
+
+	// The method only gives up on a problem with no optimum. The simplex
+	// method tells an infeasible one apart, under the same options but for
+	// Start and Incumbent, which are meant for the quadratic problem.
+	p.objective = make([]float64, len(p.objective))
+	options.Start, options.Incumbent = nil, nil
+
+	feasibility, e := solveProblem(ctx, p, options)
+	result := Result{Status: feasibility.Status, Iterations: ip.iterations + feasibility.Iterations}
+
+	switch {
+	case e != nil:
+	case result.Status == Optimal && ip.unbounded:
+		result.Status = Unbounded
+	case result.Status == Optimal:
+		e = ErrNumerical
+	}
+
+	return result, e
+}
+
+// fixIntegers returns p with integer columns fixed at their values in start,
+// rounded, and no integer columns left.
+func fixIntegers(p problem, start []float64) (problem, error) {
+
+	lower := append([]float64{}, p.lower...)
+	upper := append([]float64{}, p.upper...)
+
+	for j, integer := range p.integer {
+		if !integer {
+			continue
+		}
+		x := math.Round(start[j])
+		if x < p.lower[j] || x > p.upper[j] {
+			return p, fmt.Errorf("%w: start of column %d is out of its bounds", ErrOptions, j)
+		}
+		lower[j], upper[j] = x, x
+	}
+
+	p.lower, p.upper = lower, upper
+	p.integer = nil
+
+	return p, nil
+}
+
+// checkSquare validates a square of a quadratic objective of m.
+func (m *Model) checkSquare(s Square) error {
+
+	for v, x := range s.Expr {
+		if v < 0 || int(v) >= len(m.variables) {
+			return ErrUnknownVar
+		}
+		if !isFinite(x) {
+			return ErrInvalidValue
+		}
+	}
+	if !isFinite(s.Target) {
+		return fmt.Errorf("%w: target %g", ErrInvalidValue, s.Target)
+	}
+	if !isFinite(s.Weight) || s.Weight < 0.0 {
+		return fmt.Errorf("%w: weight %g", ErrInvalidValue, s.Weight)
+	}
+
+	return nil
+}
@@ -0,0 +1,244 @@
+package simplex
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestSolveQuadratic(t *testing.T) {
+
+	inf := math.Inf(1)
+
+	// badlyScaled has rows 1e4 apart, with the same optimum whether scaled
+	// or not.
+	badlyScaled := func(m *Model) []Square {
+		x := m.AddVar("x", 0, inf)
+		y := m.AddVar("y", 0, inf)
+		m.AddConstraint("big", Expr{x: 1e4, y: 1e4}, LE, 2e4)
+		m.AddConstraint("small", Expr{x: 1, y: -1}, EQ, 0)
+		m.Minimize(Expr{})
+		return []Square{{Expr: Expr{x: 1, y: 1}, Target: 4, Weight: 1}}
+	}
+
+	tests := []struct {
+		name    string
+		build   func(m *Model) []Square
+		options Options
+
+		status    Status
+		objective float64
+		values    []float64
+		duals     []float64
+	}{
+		{
+			// min (x - 3)² is held at x = 2, where loosening the row by one
+			// would save 2(3 - x) = 2.
+			name: "constrained",
+			build: func(m *Model) []Square {
+				x := m.AddVar("x", 0, 10)
+				m.AddConstraint("most", Expr{x: 1}, LE, 2)
+				m.Minimize(Expr{})
+				return []Square{{Expr: Expr{x: 1}, Target: 3, Weight: 1}}
+			},
+			status:    Optimal,
+			objective: 1,
+			values:    []float64{2},
+			duals:     []float64{-2},
+		},
+		{
+			name: "unconstrained",
+			build: func(m *Model) []Square {
+				x := m.AddVar("x", 0, 10)
+				y := m.AddVar("y", -5, 5)
+				m.AddConstraint("sum", Expr{x: 1, y: 1}, LE, 10)
+				m.Minimize(Expr{})
+				return []Square{
+					{Expr: Expr{x: 1}, Target: 3, Weight: 1},
+					{Expr: Expr{y: 1}, Target: -1, Weight: 2},
+				}
+			},
+			status:    Optimal,
+			objective: 0,
+			values:    []float64{3, -1},
+			duals:     []float64{0},
+		},
+		{
+			// max x - (x - 3)² is at 1 = 2(x - 3).
+			name: "maximized",
+			build: func(m *Model) []Square {
+				x := m.AddVar("x", 0, inf)
+				m.Maximize(Expr{x: 1})
+				return []Square{{Expr: Expr{x: 1}, Target: 3, Weight: 1}}
+			},
+			status:    Optimal,
+			objective: 3.25,
+			values:    []float64{3.5},
+		},
+		{
+			name:      "unscaled",
+			build:     badlyScaled,
+			options:   Options{Scaling: NoScaling},
+			status:    Optimal,
+			objective: 4,
+			values:    []float64{1, 1},
+			duals:     []float64{-4e-4, 0},
+		},
+		{
+			name:      "scaled",
+			build:     badlyScaled,
+			status:    Optimal,
+			objective: 4,
+			values:    []float64{1, 1},
+			duals:     []float64{-4e-4, 0},
+		},
+		{
+			// The integer x is kept at its start, rounded, and y makes up
+			// the rest of the sum.
+			name: "integer start",
+			build: func(m *Model) []Square {
+				x := m.AddIntVar("x", 0, 10)
+				y := m.AddVar("y", 0, 10)
+				m.Minimize(Expr{})
+				return []Square{{Expr: Expr{x: 1, y: 1}, Target: 3.5, Weight: 1}}
+			},
+			options:   Options{Start: []float64{2.2, 0}},
+			status:    Optimal,
+			objective: 0,
+			values:    []float64{2, 1.5},
+		},
+		{
+			name: "infeasible",
+			build: func(m *Model) []Square {
+				x := m.AddVar("x", 0, 10)
+				m.AddConstraint("most", Expr{x: 1}, LE, 1)
+				m.AddConstraint("least", Expr{x: 1}, GE, 2)
+				m.Minimize(Expr{})
+				return []Square{{Expr: Expr{x: 1}, Target: 3, Weight: 1}}
+			},
+			status: Infeasible,
+		},
+		{
+			// No square bounds y.
+			name: "unbounded",
+			build: func(m *Model) []Square {
+				x := m.AddVar("x", 0, 10)
+				y := m.AddVar("y", 0, inf)
+				m.Maximize(Expr{y: 1})
+				return []Square{{Expr: Expr{x: 1}, Target: 3, Weight: 1}}
+			},
+			status: Unbounded,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			m := NewModel()
+			squares := test.build(m)
+
+			s, e := SolveQuadratic(context.Background(), m, squares, test.options)
+			if e != nil {
+				t.Fatal(e)
+			}
+			if s.Status != test.status {
+				t.Fatalf("status %v, want %v", s.Status, test.status)
+			}
+			if s.Status != Optimal {
+				return
+			}
+
+			if !closeTo(s.Objective, test.objective, 1e-6) {
+				t.Errorf("objective %g, want %g", s.Objective, test.objective)
+			}
+			for j, want := range test.values {
+				if !closeTo(s.Values[j], want, 1e-6) {
+					t.Errorf("values %v, want %v", s.Values, test.values)
+					break
+				}
+			}
+			for i, want := range test.duals {
+				if !closeTo(s.Duals[i], want, 1e-6) {
+					t.Errorf("duals %v, want %v", s.Duals, test.duals)
+					break
+				}
+			}
+			if s.ObjectiveRanges != nil || s.LowerRanges != nil {
+				t.Errorf("ranges %v and %v of a quadratic problem", s.ObjectiveRanges, s.LowerRanges)
+			}
+		})
+	}
+}
+
+// TestSolveQuadraticFallback tests that the simplex method telling an
+// infeasible problem apart is given the options of the quadratic one.
+func TestSolveQuadraticFallback(t *testing.T) {
+
+	// x + y is at most 1 and at least 1 + 1e-4, a gap the interior point
+	// method does not close but a feasibility tolerance of 1e-3 does.
+	m := NewModel()
+	x := m.AddVar("x", 0, 10)
+	y := m.AddVar("y", 0, 10)
+	m.AddConstraint("most", Expr{x: 1, y: 1}, LE, 1)
+	m.AddConstraint("least", Expr{x: 1, y: 1}, GE, 1+1e-4)
+	squares := []Square{{Expr: Expr{x: 1}, Target: 3, Weight: 1}}
+
+	solve := func(options Options) (*Solution, error) {
+		return SolveQuadratic(context.Background(), m, squares, options)
+	}
+
+	simplex, e := solve(Options{})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if simplex.Status != Infeasible {
+		t.Fatalf("status %v", simplex.Status)
+	}
+	for _, scaling := range []Scaling{Equilibration, NoScaling} {
+		if s, e := solve(Options{Scaling: scaling}); e != nil || s.Status != Infeasible {
+			t.Errorf("%v scaling: status %v, error %v", scaling, s.Status, e)
+		}
+	}
+
+	// The interior point method runs twice.
+	interior, e := solve(Options{Method: InteriorPoint})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if interior.Status != Infeasible || interior.Iterations <= simplex.Iterations {
+		t.Errorf("status %v after %d iterations, %d by the simplex method", interior.Status, interior.Iterations, simplex.Iterations)
+	}
+
+	// Feasible within the tolerance, the problem had an optimum the interior
+	// point method did not find.
+	if _, e := solve(Options{FeasibilityTolerance: 1e-3}); !errors.Is(e, ErrNumerical) {
+		t.Errorf("error %v, want %v", e, ErrNumerical)
+	}
+}
+
+func TestSolveQuadraticInvalid(t *testing.T) {
+
+	m := NewModel()
+	x := m.AddVar("x", 0, 10)
+	n := m.AddIntVar("n", 0, 10)
+
+	tests := []struct {
+		name    string
+		squares []Square
+		options Options
+		want    error
+	}{
+		{"negative weight", []Square{{Expr: Expr{x: 1}, Weight: -1}}, Options{Start: []float64{0, 0}}, ErrInvalidValue},
+		{"infinite target", []Square{{Expr: Expr{x: 1}, Target: math.Inf(1), Weight: 1}}, Options{Start: []float64{0, 0}}, ErrInvalidValue},
+		{"unknown variable", []Square{{Expr: Expr{n + 1: 1}, Weight: 1}}, Options{Start: []float64{0, 0}}, ErrUnknownVar},
+		{"no start", nil, Options{}, ErrOptions},
+		{"start out of bounds", nil, Options{Start: []float64{0, 11}}, ErrOptions},
+	}
+
+	for _, test := range tests {
+		if _, e := SolveQuadratic(context.Background(), m, test.squares, test.options); !errors.Is(e, test.want) {
+			t.Errorf("%s: error %v, want %v", test.name, e, test.want)
+		}
+	}
+}
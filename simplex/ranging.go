@@ -0,0 +1,197 @@
+package simplex
+
+import "math"
+
+// duals returns duals of rows and reduced costs of structurals from the
+// phase 2 reduced costs, in the sense of the objective of p. Reduced costs are
+// `c - y·A` of the internally minimized objective, and a logical column is a
+// unit column with zero cost, so its reduced cost is the negated dual of its
+// row.
+func (t *solver) duals(p problem) ([]float64, []float64) {
+
+	sign := 1.0
+	if p.sense == Maximization {
+		sign = -1.0
+	}
+
+	duals := make([]float64, p.nConstraints)
+	for i, c := range p.constraints {
+		duals[c] = -sign * t.reduced[t.nStructurals+i]
+	}
+
+	reducedCosts := make([]float64, t.nStructurals)
+	for j := range reducedCosts {
+		reducedCosts[j] = sign * t.reduced[j]
+	}
+
+	return duals, reducedCosts
+}
+
+// objectiveRanges returns, for every structural, the interval of objective
+// coefficients over which the current basis stays optimal.
+func (t *solver) objectiveRanges(p problem) []Range {
+
+	ranges := make([]Range, t.nStructurals)
+
+	for j := range ranges {
+
+		low, high := math.Inf(-1), math.Inf(1)
+		d := t.reduced[j]
+
+		if !t.basic[j] {
+			switch {
+			case t.lower[j] == t.upper[j]:
+			case math.IsInf(t.lower[j], -1) && math.IsInf(t.upper[j], 1):
+				low, high = 0.0, 0.0
+			case t.x[j] == t.lower[j]:
+				low = math.Min(-d, 0.0)
+			default:
+				high = math.Max(-d, 0.0)
+			}
+		} else {
+			low, high = t.basicCostRange(t.row(j))
+		}
+
+		// The internal objective is minimized, a maximized coefficient is
+		// its negation.
+		c := p.objective[j]
+		if p.sense == Maximization {
+			low, high = -high, -low
+		}
+		ranges[j] = Range{c + low, c + high}
+	}
+
+	return ranges
+}
+
+// basicCostRange returns how much the internal cost of the basic column with
+// row rho of the inverse basis can change while every nonbasic reduced cost
+// keeps its sign.
+func (t *solver) basicCostRange(rho []float64) (float64, float64) {
+
+	low, high := math.Inf(-1), math.Inf(1)
+
+	for k := 0; k < t.firstArtificial; k++ {
+
+		if t.basic[k] || t.lower[k] == t.upper[k] {
+			continue
+		}
+
+		alpha := t.dot(k, rho)
+		if math.Abs(alpha) <= t.pivotTolerance {
+			continue
+		}
+
+		// The reduced cost of k becomes d - delta*alpha.
+		d := t.reduced[k]
+		limit := d / alpha
+
+		free := math.IsInf(t.lower[k], -1) && math.IsInf(t.upper[k], 1)
+		atLower := t.x[k] == t.lower[k]
+
+		if free {
+			return 0.0, 0.0
+		}
+		if atLower == (alpha > 0.0) {
+			high = math.Min(high, math.Max(limit, 0.0))
+		} else {
+			low = math.Max(low, math.Min(limit, 0.0))
+		}
+	}
+
+	return low, high
+}
+
+// boundRanges returns, for every constraint, the intervals of its lower and
+// upper bound over which the current basis stays feasible.
+func (t *solver) boundRanges(p problem) ([]Range, []Range) {
+
+	unlimited := Range{math.Inf(-1), math.Inf(1)}
+
+	lowerRanges := make([]Range, p.nConstraints)
+	upperRanges := make([]Range, p.nConstraints)
+	for c := range lowerRanges {
+		lowerRanges[c] = unlimited
+		upperRanges[c] = unlimited
+	}
+
+	activities := t.activities()
+
+	for i, c := range p.constraints {
+
+		logical := t.nStructurals + i
+		lower, upper := p.rowLower[i], p.rowUpper[i]
+		activity := activities[i]
+
+		lowerRanges[c] = Range{math.Inf(-1), activity}
+		upperRanges[c] = Range{activity, math.Inf(1)}
+
+		if t.basic[logical] {
+			continue
+		}
+
+		low, high := t.rhsRange(logical)
+
+		switch {
+		case lower == upper:
+			lowerRanges[c] = Range{lower + low, lower + high}
+			upperRanges[c] = lowerRanges[c]
+		case t.isAt(activity, upper):
+			upperRanges[c] = Range{upper + math.Max(low, lower-upper), upper + high}
+		case t.isAt(activity, lower):
+			lowerRanges[c] = Range{lower + low, lower + math.Min(high, upper-lower)}
+		}
+	}
+
+	return lowerRanges, upperRanges
+}
+
+// activities returns the values of the left hand sides of all rows.
+func (t *solver) activities() []float64 {
+
+	activities := make([]float64, t.nRows)
+
+	for j := 0; j < t.nStructurals; j++ {
+		if t.x[j] != 0.0 {
+			t.scatter(j, t.x[j], activities)
+		}
+	}
+
+	return activities
+}
+
+// isAt reports whether x is at a finite bound within the feasibility
+// tolerance.
+func (t *solver) isAt(x float64, bound float64) bool {
+	return !math.IsInf(bound, 0) && math.Abs(x-bound) <= t.feasibilityTolerance*(1.0+math.Abs(bound))
+}
+
+// rhsRange returns how much the right hand side of the row of given logical
+// column can change while every basic column stays within its bounds. Basic
+// values move by delta times the column of the logical.
+func (t *solver) rhsRange(logical int) (float64, float64) {
+
+	low, high := math.Inf(-1), math.Inf(1)
+	column := t.column(logical)
+
+	for i, j := range t.basis {
+
+		alpha := column[i]
+		if math.Abs(alpha) <= t.pivotTolerance {
+			continue
+		}
+
+		toLower := (t.lower[j] - t.x[j]) / alpha
+		toUpper := (t.upper[j] - t.x[j]) / alpha
+
+		if alpha > 0.0 {
+			low = math.Max(low, math.Min(toLower, 0.0))
+			high = math.Min(high, math.Max(toUpper, 0.0))
+		} else {
+			low = math.Max(low, math.Min(toUpper, 0.0))
+			high = math.Min(high, math.Max(toLower, 0.0))
+		}
+	}
+
+	return low, high
+}
@@ -0,0 +1,689 @@
+package simplex
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// solver is a bounded-variable revised simplex method. Every row i gets a
+// logical column with coefficient 1, `A[i]·x + s[i] = rhs[i]`, whose bounds
+// encode the range of the row, so a ranged row needs no second row. Rows whose
+// logical is out of its bounds at the starting point get an artificial column
+// as well, a unit column signed so that the artificial starts non-negative.
+//
+// Columns are laid out as structurals, logicals and artificials. Only the
+// structural columns are stored, in sparse form, and the basis is kept as a
+// factorization instead of a tableau, so a pivot costs a few solves with the
+// basis and one pass over the nonzeros of the matrix.
+type solver struct {
+	matrix sparseMatrix
+	rhs    []float64
+
+	nRows           int
+	nStructurals    int
+	firstArtificial int
+	nColumns        int
+
+	artificialRows  []int
+	artificialSigns []float64
+
+	basis  []int
+	basic  []bool
+	factor factorization
+
+	x     []float64
+	lower []float64
+	upper []float64
+
+	cost    []float64
+	reduced []float64
+	weights []float64
+
+	pricing    Pricing
+	degenerate int
+	iterations int
+
+	feasibilityTolerance float64
+	optimalityTolerance  float64
+	pivotTolerance       float64
+
+	limits
+}
+
+func newSolver(p problem) (*solver, error) {
+	return newSolverAt(p, nil)
+}
+
+// newSolverAt starts a solver with structurals at values, clamped to their
+// bounds, instead of at their starting values when values is nil. Structurals
+// strictly between their bounds are nonbasic all the same.
+func newSolverAt(p problem, values []float64) (*solver, error) {
+
+	nStructurals := len(p.objective)
+	nRows := len(p.rowLower)
+	firstLogical := nStructurals
+
+	t := &solver{
+		matrix:          p.matrix,
+		rhs:             make([]float64, nRows),
+		nRows:           nRows,
+		nStructurals:    nStructurals,
+		firstArtificial: firstLogical + nRows,
+		basis:           make([]int, nRows),
+
+		feasibilityTolerance: defaultFeasibilityTolerance,
+		optimalityTolerance:  defaultOptimalityTolerance,
+		pivotTolerance:       defaultPivotTolerance,
+	}
+
+	for j := 0; j < nStructurals; j++ {
+		t.lower = append(t.lower, p.lower[j])
+		t.upper = append(t.upper, p.upper[j])
+		if values == nil {
+			t.x = append(t.x, startingValue(p.lower[j], p.upper[j]))
+		} else {
+			t.x = append(t.x, math.Min(math.Max(values[j], p.lower[j]), p.upper[j]))
+		}
+	}
+
+	for i := 0; i < nRows; i++ {
+		var lower, upper float64
+		t.rhs[i], lower, upper = logicalBounds(p.rowLower[i], p.rowUpper[i])
+		t.lower = append(t.lower, lower)
+		t.upper = append(t.upper, upper)
+		t.x = append(t.x, 0.0)
+	}
+
+	residuals := make([]float64, nRows)
+	copy(residuals, t.rhs)
+	for j := 0; j < nStructurals; j++ {
+		if t.x[j] != 0.0 {
+			rows, values := t.matrix.column(j)
+			for k, i := range rows {
+				residuals[i] -= values[k] * t.x[j]
+			}
+		}
+	}
+
+	// Place logicals at their starting values and give an artificial column
+	// to rows where the logical cannot take up the residual.
+	for i, r := range residuals {
+
+		logical := firstLogical + i
+
+		sign := 0.0
+		if r < t.lower[logical] {
+			t.x[logical] = t.lower[logical]
+			sign = -1.0
+		} else if r > t.upper[logical] {
+			t.x[logical] = t.upper[logical]
+			sign = 1.0
+		} else {
+			t.x[logical] = r
+			t.basis[i] = logical
+			continue
+		}
+
+		t.basis[i] = firstLogical + nRows + len(t.artificialRows)
+		t.artificialRows = append(t.artificialRows, i)
+		t.artificialSigns = append(t.artificialSigns, sign)
+		t.lower = append(t.lower, 0.0)
+		t.upper = append(t.upper, math.Inf(1))
+		t.x = append(t.x, sign*(r-t.x[logical]))
+	}
+
+	t.nColumns = len(t.x)
+	t.basic = make([]bool, t.nColumns)
+	for _, j := range t.basis {
+		t.basic[j] = true
+	}
+
+	t.cost = make([]float64, t.nColumns)
+	t.reduced = make([]float64, t.nColumns)
+	t.weights = make([]float64, t.nColumns)
+
+	return t, t.refactor()
+}
+
+// scatter adds n times column j to the dense vector v.
+func (t *solver) scatter(j int, n float64, v []float64) {
+
+	switch {
+	case j < t.nStructurals:
+		rows, values := t.matrix.column(j)
+		for k, i := range rows {
+			v[i] += n * values[k]
+		}
+	case j < t.firstArtificial:
+		v[j-t.nStructurals] += n
+	default:
+		a := j - t.firstArtificial
+		v[t.artificialRows[a]] += n * t.artificialSigns[a]
+	}
+}
+
+// entries returns the rows and values of the nonzeros of column j.
+func (t *solver) entries(j int) ([]int, []float64) {
+
+	switch {
+	case j < t.nStructurals:
+		return t.matrix.column(j)
+	case j < t.firstArtificial:
+		return []int{j - t.nStructurals}, []float64{1.0}
+	}
+
+	a := j - t.firstArtificial
+	return []int{t.artificialRows[a]}, []float64{t.artificialSigns[a]}
+}
+
+// dot returns the product of column j with the dense vector y.
+func (t *solver) dot(j int, y []float64) float64 {
+
+	switch {
+	case j < t.nStructurals:
+		rows, values := t.matrix.column(j)
+		s := 0.0
+		for k, i := range rows {
+			s += values[k] * y[i]
+		}
+		return s
+	case j < t.firstArtificial:
+		return y[j-t.nStructurals]
+	}
+
+	a := j - t.firstArtificial
+	return t.artificialSigns[a] * y[t.artificialRows[a]]
+}
+
+// column returns column j in terms of the current basis.
+func (t *solver) column(j int) []float64 {
+	alpha := make([]float64, t.nRows)
+	t.scatter(j, 1.0, alpha)
+	t.factor.ftran(alpha)
+	return alpha
+}
+
+// row returns the row of the inverse of the current basis where column j is
+// basic, its product with any column is the entry of the tableau.
+func (t *solver) row(j int) []float64 {
+
+	rho := make([]float64, t.nRows)
+
+	for i, k := range t.basis {
+		if k == j {
+			rho[i] = 1.0
+		}
+	}
+
+	t.factor.btran(rho)
+
+	return rho
+}
+
+// refactor factorizes the basis from scratch and recomputes basic values.
+func (t *solver) refactor() error {
+
+	columns := newSparseMatrix(t.nRows)
+	for _, j := range t.basis {
+		columns.appendColumn(t.entries(j))
+	}
+
+	if !t.factor.factorize(columns, t.pivotTolerance) {
+		return ErrNumerical
+	}
+
+	t.refresh()
+
+	return nil
+}
+
+// refresh recomputes basic values from the right hand side to get rid of
+// error accumulated by incremental updates.
+func (t *solver) refresh() {
+
+	v := make([]float64, t.nRows)
+	copy(v, t.rhs)
+
+	for j := 0; j < t.nColumns; j++ {
+		if !t.basic[j] && t.x[j] != 0.0 {
+			t.scatter(j, -t.x[j], v)
+		}
+	}
+
+	t.factor.ftran(v)
+
+	for i, j := range t.basis {
+		t.x[j] = v[i]
+	}
+}
+
+// price computes reduced costs of all columns from the duals of the basis.
+func (t *solver) price() {
+
+	y := make([]float64, t.nRows)
+	for i, j := range t.basis {
+		y[i] = t.cost[j]
+	}
+	t.factor.btran(y)
+
+	for j := 0; j < t.nColumns; j++ {
+		if t.basic[j] {
+			t.reduced[j] = 0.0
+		} else {
+			t.reduced[j] = t.cost[j] - t.dot(j, y)
+		}
+	}
+}
+
+// ratio returns the row whose basic column leaves the basis when column q
+// with representation alpha moves in given direction, and the length of that
+// step. The row is -1 when q reaches its own opposite bound first or when the
+// step is unlimited. A column strictly between its bounds only goes as far as
+// the bound it moves towards.
+//
+// It is the two pass test of Harris: the first pass finds the longest step
+// that keeps basic columns within their bounds relaxed by the feasibility
+// tolerance, the second picks the largest pivot among the rows blocking within
+// that step, so the basis stays well conditioned. Under Bland's rule the
+// textbook test is used, with ties going to the lowest column, as that is what
+// keeps the rule from cycling.
+func (t *solver) ratio(q int, direction float64, alpha []float64) (int, float64) {
+
+	if t.bland() {
+		return t.textbookRatio(q, direction, alpha)
+	}
+
+	flip := t.distance(q, direction)
+	longest := flip
+
+	for i, j := range t.basis {
+		if math.Abs(alpha[i]) <= t.pivotTolerance {
+			continue
+		}
+		longest = math.Min(longest, t.limit(j, -direction*alpha[i], t.feasibilityTolerance))
+	}
+
+	if flip <= longest {
+		return -1, flip
+	}
+
+	row := -1
+	step := math.Inf(1)
+	largest := 0.0
+
+	for i, j := range t.basis {
+		a := math.Abs(alpha[i])
+		if a <= t.pivotTolerance {
+			continue
+		}
+		limit := t.limit(j, -direction*alpha[i], 0.0)
+		if limit <= longest && a > largest {
+			row = i
+			step = limit
+			largest = a
+		}
+	}
+
+	return row, step
+}
+
+// textbookRatio is the ratio test that takes the shortest step.
+func (t *solver) textbookRatio(q int, direction float64, alpha []float64) (int, float64) {
+
+	row := -1
+	step := t.distance(q, direction)
+
+	for i, j := range t.basis {
+
+		if math.Abs(alpha[i]) <= t.pivotTolerance {
+			continue
+		}
+
+		limit := t.limit(j, -direction*alpha[i], 0.0)
+
+		if limit < step || (limit == step && row != -1 && j < t.basis[row]) {
+			row = i
+			step = limit
+		}
+	}
+
+	return row, step
+}
+
+// distance returns how far nonbasic column q can move in given direction
+// before it reaches its bound.
+func (t *solver) distance(q int, direction float64) float64 {
+	if direction > 0.0 {
+		return t.upper[q] - t.x[q]
+	}
+	return t.x[q] - t.lower[q]
+}
+
+// limit returns how far basic column j can go at given rate of change before
+// it passes one of its bounds by tolerance. The limit is never negative.
+func (t *solver) limit(j int, rate float64, tolerance float64) float64 {
+
+	limit := math.Inf(1)
+
+	if rate < 0.0 && !math.IsInf(t.lower[j], -1) {
+		limit = (t.x[j] - t.lower[j] + tolerance) / -rate
+	} else if rate > 0.0 && !math.IsInf(t.upper[j], 1) {
+		limit = (t.upper[j] - t.x[j] + tolerance) / rate
+	}
+
+	return math.Max(limit, 0.0)
+}
+
+// move changes column q by direction*step and updates basic columns.
+func (t *solver) move(q int, direction float64, step float64, alpha []float64) {
+
+	if step == 0.0 {
+		return
+	}
+
+	for i, j := range t.basis {
+		t.x[j] -= direction * step * alpha[i]
+	}
+
+	t.x[q] += direction * step
+}
+
+// pivot makes column q with representation alpha basic in given row.
+func (t *solver) pivot(row int, q int, alpha []float64) {
+
+	if t.pricing == SteepestEdge {
+		t.updateWeights(row, q, alpha)
+	}
+
+	t.factor.update(row, alpha)
+
+	t.basic[t.basis[row]] = false
+	t.basic[q] = true
+	t.basis[row] = q
+}
+
+// optimize pivots on the current costs until none of the first nColumns
+// columns can improve them.
+func (t *solver) optimize(nColumns int) (Status, error) {
+
+	t.degenerate = 0
+	for j := range t.weights {
+		t.weights[j] = 1.0
+	}
+
+	for {
+		if t.factor.stale() {
+			if e := t.refactor(); e != nil {
+				return 0, e
+			}
+		}
+
+		t.price()
+
+		q, direction := t.entering(nColumns)
+		if q == -1 {
+			t.refresh()
+			return Optimal, nil
+		}
+
+		alpha := t.column(q)
+
+		row, step := t.ratio(q, direction, alpha)
+		if math.IsInf(step, 1) {
+			return Unbounded, nil
+		}
+		if t.stop(t.iterations) {
+			return LimitReached, nil
+		}
+
+		t.move(q, direction, step, alpha)
+		t.countDegenerate(step)
+
+		if row == -1 {
+			if direction > 0.0 {
+				t.x[q] = t.upper[q]
+			} else {
+				t.x[q] = t.lower[q]
+			}
+		} else {
+			leaving := t.basis[row]
+			if -direction*alpha[row] < 0.0 {
+				t.x[leaving] = t.lower[leaving]
+			} else {
+				t.x[leaving] = t.upper[leaving]
+			}
+			t.pivot(row, q, alpha)
+		}
+
+		t.iterations++
+
+		if !isFinite(t.x[q]) {
+			return 0, ErrNumerical
+		}
+	}
+}
+
+// limits are the limits of options on a solve and the context it runs in.
+type limits struct {
+	maxIterations int
+	deadline      time.Time
+	done          <-chan struct{}
+}
+
+// newLimits returns the limits of options on a solve that starts now.
+func newLimits(ctx context.Context, options Options) limits {
+
+	l := limits{maxIterations: options.maxIterations(), done: ctx.Done()}
+	if options.TimeLimit > 0 {
+		l.deadline = time.Now().Add(options.TimeLimit)
+	}
+
+	return l
+}
+
+// stop reports whether a limit or the context ends the solve before the next
+// pivot, after given iterations.
+func (l limits) stop(iterations int) bool {
+
+	if iterations >= l.maxIterations {
+		return true
+	}
+	if !l.deadline.IsZero() && !time.Now().Before(l.deadline) {
+		return true
+	}
+
+	select {
+	case <-l.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// driveOutArtificials pivots artificial columns that are still basic at zero
+// level after phase 1 out of the basis and fixes all artificials at zero.
+// Rows where this is not possible are redundant and keep their artificial
+// column at zero.
+func (t *solver) driveOutArtificials() error {
+
+	for row, column := range t.basis {
+
+		if column < t.firstArtificial {
+			continue
+		}
+
+		rho := t.row(column)
+
+		pivotColumn := -1
+		pivotMaximum := t.pivotTolerance
+
+		for j := 0; j < t.firstArtificial; j++ {
+			if a := math.Abs(t.dot(j, rho)); !t.basic[j] && a > pivotMaximum {
+				pivotColumn = j
+				pivotMaximum = a
+			}
+		}
+
+		if pivotColumn != -1 {
+			t.pivot(row, pivotColumn, t.column(pivotColumn))
+		}
+	}
+
+	for j := t.firstArtificial; j < t.nColumns; j++ {
+		t.x[j] = 0.0
+		t.upper[j] = 0.0
+	}
+
+	return t.refactor()
+}
+
+// configure applies the pricing, tolerances and limits of options to the
+// solver.
+func (t *solver) configure(ctx context.Context, options Options) {
+	t.pricing = options.pricing()
+	t.feasibilityTolerance = options.feasibilityTolerance()
+	t.optimalityTolerance = options.optimalityTolerance()
+	t.pivotTolerance = options.pivotTolerance()
+	t.limits = newLimits(ctx, options)
+}
+
+// twoPhase runs both phases of the simplex method on p from the starting
+// basis. It also reports whether phase 2 was reached, that is whether the
+// solver is at a feasible point.
+func (t *solver) twoPhase(p problem) (Status, bool, error) {
+
+	// Phase 1 minimizes the sum of artificials.
+	for j := t.firstArtificial; j < t.nColumns; j++ {
+		t.cost[j] = 1.0
+	}
+
+	status, e := t.optimize(t.firstArtificial)
+	if e != nil {
+		return status, false, e
+	}
+	if status == Unbounded {
+		return status, false, ErrNumerical
+	}
+	if status != Optimal {
+		return status, false, nil
+	}
+
+	infeasibility := 0.0
+	for j := t.firstArtificial; j < t.nColumns; j++ {
+		infeasibility += t.x[j]
+	}
+	if infeasibility > t.feasibilityTolerance {
+		return Infeasible, false, nil
+	}
+
+	if e := t.driveOutArtificials(); e != nil {
+		return status, false, e
+	}
+
+	t.setObjective(p)
+
+	status, e = t.optimize(t.firstArtificial)
+	if e != nil || (status != Optimal && status != LimitReached) {
+		return status, false, e
+	}
+
+	// Phase 2 only moves between feasible points, each at least as good as
+	// the one before, so a stopped solve still has its best point at hand.
+	if status == LimitReached {
+		t.refresh()
+	}
+
+	return status, true, nil
+}
+
+// setObjective sets the costs of phase 2, the objective of p negated when it
+// is maximized.
+func (t *solver) setObjective(p problem) {
+
+	for j := range t.cost {
+		t.cost[j] = 0.0
+	}
+	for j, c := range p.objective {
+		if p.sense == Minimization {
+			t.cost[j] = c
+		} else {
+			t.cost[j] = -c
+		}
+	}
+}
+
+// point returns the values of structurals and the objective of p at them.
+func (t *solver) point(p problem) ([]float64, float64, error) {
+
+	values := make([]float64, t.nStructurals)
+	objective := 0.0
+
+	for j := range values {
+		values[j] = t.x[j]
+		objective += p.objective[j] * t.x[j]
+		if !isFinite(t.x[j]) {
+			return nil, 0.0, ErrNumerical
+		}
+	}
+
+	return values, objective, nil
+}
+
+// solve runs both phases of the simplex method on p, after the interior point
+// method when options select it. The error of ctx is returned when it stops
+// the solve.
+func solve(ctx context.Context, p problem, options Options) (Result, error) {
+
+	if e := options.check(); e != nil {
+		return Result{}, e
+	}
+
+	var t *solver
+	iterations := 0
+
+	if options.method() == InteriorPoint {
+		var result Result
+		var e error
+		t, result, e = interiorStart(ctx, p, options, options.Crossover)
+		if t == nil {
+			return result, e
+		}
+		iterations = result.Iterations
+	} else {
+		var e error
+		t, e = newSolver(p)
+		if e != nil {
+			return Result{}, e
+		}
+		t.configure(ctx, options)
+	}
+
+	status, feasible, e := t.twoPhase(p)
+	iterations += t.iterations
+	if e != nil {
+		return Result{Iterations: iterations}, e
+	}
+	result := Result{Status: status, Iterations: iterations}
+	if !feasible {
+		if status == LimitReached {
+			return result, ctx.Err()
+		}
+		return result, nil
+	}
+
+	result.Values, result.Objective, e = t.point(p)
+	if e != nil {
+		return Result{Iterations: iterations}, e
+	}
+
+	if status == LimitReached {
+		return result, ctx.Err()
+	}
+
+	result.Duals, result.ReducedCosts = t.duals(p)
+	result.ObjectiveRanges = t.objectiveRanges(p)
+	result.LowerRanges, result.UpperRanges = t.boundRanges(p)
+
+	return result, nil
+}
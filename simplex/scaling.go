@@ -0,0 +1,257 @@
+package simplex
+
+import "math"
+
+// scalingPasses is the number of passes of geometric scaling before rows and
+// columns are equilibrated.
+const scalingPasses = 4
+
+// Scaling chooses how a problem is scaled before it is solved.
+type Scaling int
+
+const (
+	// Equilibration scales rows and then columns by the geometric mean of
+	// their smallest and largest entries a few times over, and last by their
+	// largest entries, so that entries of rows that differ by orders of
+	// magnitude all come near one. Integer columns are not scaled. Factors
+	// are powers of two, which scale every number without rounding it, and
+	// results are reported for the problem as given.
+	Equilibration Scaling = iota + 1
+	// NoScaling solves the problem as given.
+	NoScaling
+)
+
+func (s Scaling) String() string {
+	switch s {
+	case Equilibration:
+		return "equilibration"
+	case NoScaling:
+		return "none"
+	}
+	return "unknown"
+}
+
+// scaling holds the factors of rows and columns of a problem. The scaled
+// problem has the matrix `R·A·C`, its columns are the columns of the problem
+// divided by their factors.
+type scaling struct {
+	rows    []float64
+	columns []float64
+}
+
+// unitScaling returns factors of p that are all one.
+func unitScaling(p problem) scaling {
+
+	s := scaling{
+		rows:    make([]float64, len(p.rowLower)),
+		columns: make([]float64, len(p.objective)),
+	}
+	for i := range s.rows {
+		s.rows[i] = 1.0
+	}
+	for j := range s.columns {
+		s.columns[j] = 1.0
+	}
+
+	return s
+}
+
+// newScaling computes the factors that equilibrate p.
+func newScaling(p problem) scaling {
+
+	s := unitScaling(p)
+
+	for pass := 0; pass < scalingPasses; pass++ {
+		s.scaleRows(p, true)
+		s.scaleColumns(p, true)
+	}
+	s.scaleRows(p, false)
+	s.scaleColumns(p, false)
+
+	for i, r := range s.rows {
+		s.rows[i] = powerOfTwo(r)
+	}
+	for j, c := range s.columns {
+		s.columns[j] = powerOfTwo(c)
+	}
+
+	return s
+}
+
+// scaleRows divides every row of the matrix as scaled so far by the geometric
+// mean of its smallest and largest entries when geometric is true, and by its
+// largest entry otherwise.
+func (s scaling) scaleRows(p problem, geometric bool) {
+
+	small := make([]float64, len(s.rows))
+	large := make([]float64, len(s.rows))
+	for i := range small {
+		small[i] = math.Inf(1)
+	}
+
+	for j, c := range s.columns {
+		rows, values := p.matrix.column(j)
+		for k, i := range rows {
+			a := math.Abs(values[k]) * c
+			small[i] = math.Min(small[i], a)
+			large[i] = math.Max(large[i], a)
+		}
+	}
+
+	for i := range s.rows {
+		if large[i] == 0.0 {
+			continue
+		}
+		if geometric {
+			s.rows[i] = 1.0 / math.Sqrt(small[i]*large[i])
+		} else {
+			s.rows[i] = 1.0 / large[i]
+		}
+	}
+}
+
+// scaleColumns divides every column other than integer ones as scaleRows
+// divides rows.
+func (s scaling) scaleColumns(p problem, geometric bool) {
+
+	for j := range s.columns {
+
+		if p.integer != nil && p.integer[j] {
+			continue
+		}
+
+		small, large := math.Inf(1), 0.0
+		rows, values := p.matrix.column(j)
+		for k, i := range rows {
+			a := math.Abs(values[k]) * s.rows[i]
+			small = math.Min(small, a)
+			large = math.Max(large, a)
+		}
+
+		if large == 0.0 {
+			continue
+		}
+		if geometric {
+			s.columns[j] = 1.0 / math.Sqrt(small*large)
+		} else {
+			s.columns[j] = 1.0 / large
+		}
+	}
+}
+
+// powerOfTwo returns the power of two nearest to x.
+func powerOfTwo(x float64) float64 {
+	return math.Exp2(math.Round(math.Log2(x)))
+}
+
+// problem returns p scaled. The pattern of the matrix is shared with p.
+func (s scaling) problem(p problem) problem {
+
+	scaled := p
+
+	scaled.objective = make([]float64, len(p.objective))
+	scaled.lower = make([]float64, len(p.lower))
+	scaled.upper = make([]float64, len(p.upper))
+	for j, c := range s.columns {
+		scaled.objective[j] = p.objective[j] * c
+		scaled.lower[j] = p.lower[j] / c
+		scaled.upper[j] = p.upper[j] / c
+	}
+
+	scaled.rowLower = make([]float64, len(p.rowLower))
+	scaled.rowUpper = make([]float64, len(p.rowUpper))
+	for i, r := range s.rows {
+		scaled.rowLower[i] = p.rowLower[i] * r
+		scaled.rowUpper[i] = p.rowUpper[i] * r
+	}
+
+	scaled.matrix.value = make([]float64, len(p.matrix.value))
+	for j, c := range s.columns {
+		for k := p.matrix.start[j]; k < p.matrix.start[j+1]; k++ {
+			scaled.matrix.value[k] = p.matrix.value[k] * s.rows[p.matrix.index[k]] * c
+		}
+	}
+
+	return scaled
+}
+
+// scale returns values of columns of the problem as values of the scaled
+// one, or values themselves when their number does not match.
+func (s scaling) scale(values []float64) []float64 {
+
+	if len(values) != len(s.columns) {
+		return values
+	}
+
+	scaled := make([]float64, len(values))
+	for j, x := range values {
+		scaled[j] = x / s.columns[j]
+	}
+
+	return scaled
+}
+
+// unscale returns values of columns of the scaled problem as values of the
+// problem.
+func (s scaling) unscale(values []float64) []float64 {
+
+	if values == nil {
+		return nil
+	}
+
+	unscaled := make([]float64, len(values))
+	for j, x := range values {
+		unscaled[j] = x * s.columns[j]
+	}
+
+	return unscaled
+}
+
+// options returns options with their start scaled and an incumbent callback
+// that sees unscaled values.
+func (s scaling) options(options Options) Options {
+
+	options.Start = s.scale(options.Start)
+
+	if incumbent := options.Incumbent; incumbent != nil {
+		options.Incumbent = func(objective float64, values []float64) bool {
+			return incumbent(objective, s.unscale(values))
+		}
+	}
+
+	return options
+}
+
+// result returns the result of a solve of p as scaled in terms of p. The
+// objective is the same in both.
+func (s scaling) result(p problem, result Result) Result {
+
+	result.Values = s.unscale(result.Values)
+
+	if result.ReducedCosts != nil {
+		for j, c := range s.columns {
+			result.ReducedCosts[j] /= c
+		}
+	}
+	if result.ObjectiveRanges != nil {
+		for j, c := range s.columns {
+			r := &result.ObjectiveRanges[j]
+			r.Lower, r.Upper = r.Lower/c, r.Upper/c
+		}
+	}
+
+	for i, c := range p.constraints {
+		r := s.rows[i]
+		if result.Duals != nil {
+			result.Duals[c] *= r
+		}
+		if result.LowerRanges != nil {
+			result.LowerRanges[c].Lower /= r
+			result.LowerRanges[c].Upper /= r
+			result.UpperRanges[c].Lower /= r
+			result.UpperRanges[c].Upper /= r
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,88 @@
+package simplex
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestScaling(t *testing.T) {
+
+	// The textbook problem, max 3x + 5y, with its first row times 1e4 and its
+	// last times 1e-3. Duals and ranges of a row scale with it.
+	m := NewModel()
+	x := m.AddVar("x", 0, math.Inf(1))
+	y := m.AddVar("y", 0, math.Inf(1))
+	m.AddConstraint("plant1", Expr{x: 1e4}, LE, 4e4)
+	m.AddConstraint("plant2", Expr{y: 2}, LE, 12)
+	m.AddConstraint("plant3", Expr{x: 3e-3, y: 2e-3}, LE, 18e-3)
+	m.Maximize(Expr{x: 3, y: 5})
+
+	s := newScaling(m.problem())
+	for i, r := range s.rows {
+		if fraction, _ := math.Frexp(r); r == 1.0 || fraction != 0.5 {
+			t.Errorf("row %d factor %g, want a power of two other than one", i, r)
+		}
+	}
+
+	unscaled, e := Solve(m, Options{Scaling: NoScaling})
+	if e != nil {
+		t.Fatal(e)
+	}
+	scaled, e := Solve(m, Options{Scaling: Equilibration})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	for _, r := range []*Solution{unscaled, scaled} {
+		if r.Status != Optimal || !near(r.Objective, 36) || !nearAll(r.Values, []float64{2, 6}) {
+			t.Fatalf("status %v, objective %g at %v, want 36 at [2 6]", r.Status, r.Objective, r.Values)
+		}
+		if !nearAll(r.Duals, []float64{0, 1.5, 1e3}) {
+			t.Errorf("duals %v, want [0 1.5 1000]", r.Duals)
+		}
+		if want := []Range{{2e4, math.Inf(1)}, {6, 18}, {12e-3, 24e-3}}; !nearRanges(r.UpperRanges, want) {
+			t.Errorf("upper ranges %v, want %v", r.UpperRanges, want)
+		}
+	}
+
+	if !nearAll(scaled.ReducedCosts, unscaled.ReducedCosts) {
+		t.Errorf("reduced costs %v scaled, %v not", scaled.ReducedCosts, unscaled.ReducedCosts)
+	}
+	if !nearRanges(scaled.ObjectiveRanges, unscaled.ObjectiveRanges) {
+		t.Errorf("objective ranges %v scaled, %v not", scaled.ObjectiveRanges, unscaled.ObjectiveRanges)
+	}
+	if !nearRanges(scaled.LowerRanges, unscaled.LowerRanges) {
+		t.Errorf("lower ranges %v scaled, %v not", scaled.LowerRanges, unscaled.LowerRanges)
+	}
+}
+
+func TestOptionsTolerances(t *testing.T) {
+
+	tests := map[string]Options{
+		"negative feasibility": {FeasibilityTolerance: -1e-9},
+		"infinite feasibility": {FeasibilityTolerance: math.Inf(1)},
+		"NaN optimality":       {OptimalityTolerance: math.NaN()},
+		"negative optimality":  {OptimalityTolerance: -1e-9},
+		"negative pivot":       {PivotTolerance: -1e-9},
+		"infinite pivot":       {PivotTolerance: math.Inf(1)},
+		"negative gap":         {GapTolerance: -1e-6},
+		"NaN gap":              {GapTolerance: math.NaN()},
+		"unknown scaling":      {Scaling: NoScaling + 1},
+	}
+
+	for name, options := range tests {
+		if _, e := Solve(textbook(), options); !errors.Is(e, ErrOptions) {
+			t.Errorf("%s: error %v, want %v", name, e, ErrOptions)
+		}
+	}
+
+	// Valid tolerances other than the defaults reach the same optimum.
+	s, e := Solve(textbook(), Options{FeasibilityTolerance: 1e-6, OptimalityTolerance: 1e-8, PivotTolerance: 1e-6})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if s.Status != Optimal || !near(s.Objective, 36) {
+		t.Errorf("status %v, objective %g", s.Status, s.Objective)
+	}
+}
@@ -1,335 +1,382 @@
 package simplex
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"math"
+	"time"
 )
 
 const (
-	bigM = 1e10
-)
-
-func pivot(matrix [][]float64, baiscColumns []int) (int, int) {
-
-	lastRow := len(matrix) - 1
-	lastColumn := len(matrix[0]) - 1
-
-	rowIndex := -1
-	columnIndex := -1
-
-outer:
-	for i, column := range matrix[lastRow] {
-
-		if i >= lastColumn {
-			continue
-		}
-
-		for _, j := range baiscColumns {
-			if i == j {
-				continue outer
-			}
-		}
-
-		if column >= 0.0 {
-			continue
-		}
-
-		rowMinimum := math.MaxFloat64
-
-		for j := range matrix {
+	epsilon              = 1e-9
+	defaultMaxIterations = 50000
 
-			if j >= lastRow {
-				continue
-			}
+	defaultFeasibilityTolerance = 1e-7
+	defaultOptimalityTolerance  = 1e-9
+	defaultPivotTolerance       = 1e-7
+)
 
-			if matrix[j][i] <= 0 {
-				continue
-			}
+// Status describes how a solve finished.
+type Status int
 
-			x := matrix[j][lastColumn] / matrix[j][i]
-			if x < rowMinimum {
-				rowMinimum = x
-				rowIndex = j
-				columnIndex = i
-			}
-		}
+const (
+	// Optimal means an optimal solution was found.
+	Optimal Status = iota + 1
+	// Infeasible means no point satisfies all constraints.
+	Infeasible
+	// Unbounded means the objective can grow without limit.
+	Unbounded
+	// LimitReached means an iteration limit, a time limit or the context
+	// stopped the solver before it reached optimality.
+	LimitReached
+)
 
-		break
+func (s Status) String() string {
+	switch s {
+	case Optimal:
+		return "optimal"
+	case Infeasible:
+		return "infeasible"
+	case Unbounded:
+		return "unbounded"
+	case LimitReached:
+		return "limit reached"
 	}
-
-	return rowIndex, columnIndex
+	return "unknown"
 }
 
-func nonZeroRowForColumn(matrix [][]float64, column int, excludeRow int) int {
-
-	for i, row := range matrix {
-		if i == excludeRow {
-			continue
-		}
-		if row[column] != 0.0 {
-			return i
-		}
-	}
+// Sense is the direction of optimization.
+type Sense int
 
-	return -1
-}
+const (
+	// Maximization maximizes the objective.
+	Maximization Sense = iota + 1
+	// Minimization minimizes the objective.
+	Minimization
+)
 
-func addRows(matrix [][]float64, targetRow int, sourceRow int, n float64) {
-	for i := range matrix[targetRow] {
-		matrix[targetRow][i] += matrix[sourceRow][i] * n
+func (s Sense) String() string {
+	switch s {
+	case Maximization:
+		return "maximize"
+	case Minimization:
+		return "minimize"
 	}
+	return "unknown"
 }
 
-func scalarMultiplyRow(matrix [][]float64, targetRow int, n float64) {
-	for i := range matrix[targetRow] {
-		matrix[targetRow][i] *= n
-	}
+// Result is the outcome of a solve. Objective, Values, Duals and
+// ReducedCosts are only meaningful when Status is Optimal. When Status is
+// LimitReached, Objective and Values hold the best feasible point found so
+// far, and Values is nil if none was found.
+//
+// Duals holds a dual value for every constraint: the rate at which the
+// objective changes when the bound of the constraint that is active is
+// increased. ReducedCosts holds the rate at which the objective changes when
+// a variable is moved away from its value, zero for basic variables. Both are
+// expressed in the sense of the objective.
+//
+// ObjectiveRanges holds, for every variable, the interval its objective
+// coefficient can vary in while the optimal basis stays optimal. LowerRanges
+// and UpperRanges hold, for every constraint, the interval its lower or upper
+// bound can vary in while the optimal basis stays feasible. Bounds of an
+// equality constraint move together. Constraints the solver never saw, as
+// they bound nothing, get unlimited ranges.
+//
+// A model with integer variables is solved by branch-and-bound. Nodes counts
+// the linear programs solved, and Bound is the best objective any integer
+// solution could reach. Duals, reduced costs and ranges are not reported.
+//
+// A lexicographic solve reports in Objectives the value of every objective at
+// Values, and the rest of the result of its last stage.
+type Result struct {
+	Status       Status
+	Objective    float64
+	Values       []float64
+	Duals        []float64
+	ReducedCosts []float64
+	Iterations   int
+
+	ObjectiveRanges []Range
+	LowerRanges     []Range
+	UpperRanges     []Range
+
+	Nodes int
+	Bound float64
+
+	Objectives []float64
 }
 
-func handlePivot(matrix [][]float64, pivotRow int, pivotColumn int, saveIndexes *[]int) {
-
-	(*saveIndexes)[pivotRow] = pivotColumn
-
-	pivotCell := matrix[pivotRow][pivotColumn]
-	pivotCellInverse := 1.0 / pivotCell
-
-	scalarMultiplyRow(matrix, pivotRow, pivotCellInverse)
-
-	for row := range matrix {
-		if row == pivotRow {
-			continue
-		}
-
-		a := matrix[pivotRow][pivotColumn]
-		x := matrix[row][pivotColumn]
-
-		n := -x / a
-
-		addRows(matrix, row, pivotRow, n)
-	}
+// Range is an interval of values, either end of which may be infinite.
+type Range struct {
+	Lower float64
+	Upper float64
 }
 
-func findBasics(matrix [][]float64) ([]int, []int) {
+// Options control a solve. The zero value selects the defaults.
+//
+// Pricing defaults to Dantzig. Unless Bland is selected, a run of degenerate
+// pivots switches pricing to Bland's rule until the objective moves again, so
+// no rule cycles.
+//
+// Method defaults to SimplexMethod. InteriorPoint reports an optimal point
+// that need not be basic, with duals and reduced costs but without ranges,
+// unless Crossover is set, which moves the point to an optimal basis with the
+// simplex method so the result is the one the simplex method would report.
+// Models with integer variables cross over at every node solved from scratch,
+// as nodes warm start from the basis of their parent. When the interior point
+// method does not converge, as it does not on infeasible and unbounded
+// problems, the simplex method solves the problem from scratch.
+//
+// MaxIterations limits the number of pivots of every linear program solved,
+// 50000 by default, and the iterations of the interior point method, 200 by
+// default. TimeLimit limits the wall time of a solve, no limit by
+// default.
+//
+// Scaling defaults to Equilibration. FeasibilityTolerance is how far a value
+// may be out of its bounds, 1e-7 by default, OptimalityTolerance how far a
+// reduced cost may be on the wrong side of zero at an optimum, 1e-9 by
+// default, and PivotTolerance the smallest entry of a column the simplex
+// method pivots on or of a basis it factorizes, 1e-7 by default. Ranging and
+// the dual simplex method use them too. They apply to the problem as scaled,
+// and do not apply to the interior point method, only to the simplex method
+// of its crossover.
+//
+// The rest only apply to models with integer variables. NodeLimit limits the
+// number of nodes of branch-and-bound, no limit by default. GapTolerance is
+// the relative gap between the incumbent and the bound at which the incumbent
+// is accepted as optimal, 1e-6 by default. Incumbent, when set, is called with
+// every improving integer solution, indexed by Var, and stops the search by
+// returning false. Start, when set, is a known solution indexed by Var that
+// the search begins with as its incumbent. A start that is not feasible or
+// not integral is ignored.
+type Options struct {
+	Pricing       Pricing
+	Method        Method
+	Crossover     bool
+	MaxIterations int
+	TimeLimit     time.Duration
+
+	Scaling              Scaling
+	FeasibilityTolerance float64
+	OptimalityTolerance  float64
+	PivotTolerance       float64
+
+	NodeLimit    int
+	GapTolerance float64
+	Incumbent    func(objective float64, values []float64) bool
+	Start        []float64
+}
 
-	rows := make([]int, 0)
-	columns := make([]int, 0)
+var (
+	// ErrDimensions is returned when constraint rows and right hand sides do
+	// not match the objective.
+	ErrDimensions = errors.New("simplex: constraint dimensions do not match")
+	// ErrInvalidValue is returned when the problem contains NaN or infinite
+	// coefficients.
+	ErrInvalidValue = errors.New("simplex: problem contains NaN or infinite value")
+	// ErrOptions is returned when solver options hold an unknown value.
+	ErrOptions = errors.New("simplex: invalid options")
+	// ErrNumerical is returned when the basis becomes numerically unusable.
+	ErrNumerical = errors.New("simplex: numerical failure")
+)
 
-	ignoredColumn := len(matrix[0]) - 2
+func isFinite(x float64) bool {
+	return !math.IsNaN(x) && !math.IsInf(x, 0)
+}
 
-outer:
-	for i := range matrix[0] {
+// checkRows validates rows of given relation. The only infinite right hand
+// sides accepted are ones that bound nothing, `<= +Inf` and `>= -Inf`.
+func checkRows(lhs [][]float64, rhs []float64, relation Relation, nVariables int) error {
 
-		if i == ignoredColumn {
-			continue
-		}
+	if len(lhs) != len(rhs) {
+		return ErrDimensions
+	}
 
-		for _, j := range rows {
-			if j == i {
-				continue outer
-			}
+	for i, row := range lhs {
+		if len(row) != nVariables {
+			return ErrDimensions
 		}
-
-		rowForNonZero := -1
-		foundNonZero := false
-
-		for j := range matrix {
-			if matrix[j][i] != 0.0 {
-
-				if foundNonZero {
-					foundNonZero = false
-					break
-				}
-
-				foundNonZero = true
-				rowForNonZero = j
+		for _, x := range row {
+			if !isFinite(x) {
+				return ErrInvalidValue
 			}
 		}
-
-		if foundNonZero {
-			rows = append(rows, rowForNonZero)
-			columns = append(columns, i)
+		if !isFinite(rhs[i]) && !isUnbounding(relation, rhs[i]) {
+			return ErrInvalidValue
 		}
 	}
 
-	return rows, columns
+	return nil
 }
 
-func getResult(matrix [][]float64, saveIndexes []int, nVariables int) ([]float64, float64, bool) {
-
-	variables := make([]float64, nVariables)
-
-	lastRow := len(matrix) - 1
-	lastColumn := len(matrix[0]) - 1
-
-	for i, j := range saveIndexes {
-
-		if j >= nVariables || j == -1 {
-			continue
+func (o Options) check() error {
+	if o.Pricing < 0 || o.Pricing > SteepestEdge {
+		return fmt.Errorf("%w: pricing %d", ErrOptions, o.Pricing)
+	}
+	if o.Method < 0 || o.Method > InteriorPoint {
+		return fmt.Errorf("%w: method %d", ErrOptions, o.Method)
+	}
+	if o.MaxIterations < 0 {
+		return fmt.Errorf("%w: max iterations %d", ErrOptions, o.MaxIterations)
+	}
+	if o.TimeLimit < 0 {
+		return fmt.Errorf("%w: time limit %s", ErrOptions, o.TimeLimit)
+	}
+	if o.Scaling < 0 || o.Scaling > NoScaling {
+		return fmt.Errorf("%w: scaling %d", ErrOptions, o.Scaling)
+	}
+	for _, tolerance := range []float64{o.FeasibilityTolerance, o.OptimalityTolerance, o.PivotTolerance} {
+		if tolerance < 0.0 || !isFinite(tolerance) {
+			return fmt.Errorf("%w: tolerance %g", ErrOptions, tolerance)
 		}
-
-		variables[j] = matrix[i][lastColumn]
 	}
-
-	return variables, matrix[lastRow][lastColumn], true
+	if o.NodeLimit < 0 {
+		return fmt.Errorf("%w: node limit %d", ErrOptions, o.NodeLimit)
+	}
+	if o.GapTolerance < 0.0 || !isFinite(o.GapTolerance) {
+		return fmt.Errorf("%w: gap tolerance %g", ErrOptions, o.GapTolerance)
+	}
+	return nil
 }
 
-func zerofyCell(matrix [][]float64, cellRow int, cellColumn int) {
-
-	nonZeroRow := nonZeroRowForColumn(matrix, cellColumn, cellRow)
-	if nonZeroRow == -1 {
-		panic("No non-zero row is found")
+func (o Options) maxIterations() int {
+	if o.MaxIterations == 0 {
+		return defaultMaxIterations
 	}
-
-	a := matrix[nonZeroRow][cellColumn]
-	x := matrix[cellRow][cellColumn]
-
-	n := -x / a
-
-	addRows(matrix, cellRow, nonZeroRow, n)
+	return o.MaxIterations
 }
 
-func solutionExists(matrix [][]float64, basicRows []int, basicColumns []int, nConstraints int) bool {
-
-	lastColumn := len(matrix[0]) - 1
-
-	for _, i := range basicRows {
-		if matrix[i][lastColumn] < 0.0 {
-			return false
-		}
+func (o Options) pricing() Pricing {
+	if o.Pricing == 0 {
+		return Dantzig
 	}
+	return o.Pricing
+}
 
-	return true
+func (o Options) method() Method {
+	if o.Method == 0 {
+		return SimplexMethod
+	}
+	return o.Method
 }
 
-func makeSaveIndexes(basicColumns []int, nConstraints int) []int {
+func (o Options) scaling() Scaling {
+	if o.Scaling == 0 {
+		return Equilibration
+	}
+	return o.Scaling
+}
 
-	indexes := make([]int, nConstraints)
+func (o Options) feasibilityTolerance() float64 {
+	if o.FeasibilityTolerance == 0.0 {
+		return defaultFeasibilityTolerance
+	}
+	return o.FeasibilityTolerance
+}
 
-	for _, i := range indexes {
-		indexes[i] = -1
+func (o Options) optimalityTolerance() float64 {
+	if o.OptimalityTolerance == 0.0 {
+		return defaultOptimalityTolerance
 	}
+	return o.OptimalityTolerance
+}
 
-	for i, j := range basicColumns {
-		indexes[i] = j
+func (o Options) pivotTolerance() float64 {
+	if o.PivotTolerance == 0.0 {
+		return defaultPivotTolerance
 	}
+	return o.PivotTolerance
+}
 
-	return indexes
+// isUnbounding reports whether `a·x relation rhs` holds for any x.
+func isUnbounding(relation Relation, rhs float64) bool {
+	return (relation == LE && math.IsInf(rhs, 1)) || (relation == GE && math.IsInf(rhs, -1))
 }
 
 // Simplex maximizes given objective function subject to given constraints.
+// It is Optimize with Maximization.
 func Simplex(
 	objective []float64,
 	gtConstraintsLHS [][]float64, gtConstraintsRHS []float64,
 	ltConstraintsLHS [][]float64, ltConstraintsRHS []float64,
-	eqConstraintsLHS [][]float64, eqConstraintsRHS []float64) ([]float64, float64, bool) {
-
-	for i := range objective {
-		objective[i] *= -1
-	}
-
-	nVariables := len(objective)
-	nGTConstraints := len(gtConstraintsLHS)
-	nLTConstraints := len(ltConstraintsLHS)
-	nEQConstraints := len(eqConstraintsLHS)
-	nConstraints := nGTConstraints + nLTConstraints + nEQConstraints
-
-	matrix := make([][]float64, 0)
-
-	totalSlacks := nGTConstraints + nLTConstraints
-	totalSurpluses := nGTConstraints + nEQConstraints
+	eqConstraintsLHS [][]float64, eqConstraintsRHS []float64) (Result, error) {
 
-	slackIndex := nVariables
-	slackIndexes := make([]int, totalSlacks)
+	return Optimize(Maximization, objective,
+		gtConstraintsLHS, gtConstraintsRHS,
+		ltConstraintsLHS, ltConstraintsRHS,
+		eqConstraintsLHS, eqConstraintsRHS)
+}
 
-	surplusIndex := nVariables + totalSlacks
-	surplusIndexes := make([]int, totalSurpluses)
+// Optimize maximizes or minimizes given objective function subject to given
+// constraints over non-negative variables. Result.Objective is reported in
+// the requested sense. A `<=` row with +Inf or a `>=` row with -Inf right
+// hand side bounds nothing and is dropped. Result.Duals follow the order of
+// `>=`, `<=` and `=` rows.
+//
+// It uses the two-phase method: phase 1 minimizes the sum of artificial
+// columns to find a feasible basis, phase 2 optimizes the objective from it.
+// An error is returned only for malformed input or numerical failure,
+// infeasible and unbounded problems are reported through Result.Status.
+//
+// The arguments are never modified, so the same problem can be solved again.
+// Default Options are used.
+func Optimize(
+	sense Sense,
+	objective []float64,
+	gtConstraintsLHS [][]float64, gtConstraintsRHS []float64,
+	ltConstraintsLHS [][]float64, ltConstraintsRHS []float64,
+	eqConstraintsLHS [][]float64, eqConstraintsRHS []float64) (Result, error) {
 
-	for i := 0; i < totalSlacks; i++ {
-		for j := range ltConstraintsLHS {
-			element := 0.0
-			if i == j {
-				element = 1.0
-			}
-			ltConstraintsLHS[j] = append(ltConstraintsLHS[j], element)
-		}
-		for j := range gtConstraintsLHS {
-			index := j + nLTConstraints
-			element := 0.0
-			if i == index {
-				element = -1.0
-			}
-			gtConstraintsLHS[j] = append(gtConstraintsLHS[j], element)
+	for _, x := range objective {
+		if !isFinite(x) {
+			return Result{}, ErrInvalidValue
 		}
-		for j := range eqConstraintsLHS {
-			eqConstraintsLHS[j] = append(eqConstraintsLHS[j], 0.0)
-		}
-		objective = append(objective, 0.0)
-		slackIndexes[i] = slackIndex
-		slackIndex++
 	}
 
-	for i := 0; i < totalSurpluses; i++ {
-		for j := range eqConstraintsLHS {
-			element := 0.0
-			if i == j {
-				element = 1.0
-			}
-			eqConstraintsLHS[j] = append(eqConstraintsLHS[j], element)
+	for _, e := range []error{
+		checkRows(gtConstraintsLHS, gtConstraintsRHS, GE, len(objective)),
+		checkRows(ltConstraintsLHS, ltConstraintsRHS, LE, len(objective)),
+		checkRows(eqConstraintsLHS, eqConstraintsRHS, EQ, len(objective)),
+	} {
+		if e != nil {
+			return Result{}, e
 		}
-		for j := range gtConstraintsLHS {
-			index := j + nEQConstraints
-			element := 0.0
-			if i == index {
-				element = 1.0
-			}
-			gtConstraintsLHS[j] = append(gtConstraintsLHS[j], element)
-		}
-		for j := range ltConstraintsLHS {
-			ltConstraintsLHS[j] = append(ltConstraintsLHS[j], 0.0)
-		}
-		objective = append(objective, bigM)
-		surplusIndexes[i] = surplusIndex
-		surplusIndex++
 	}
 
-	for j := range eqConstraintsLHS {
-		eqConstraintsLHS[j] = append(eqConstraintsLHS[j], 0.0, eqConstraintsRHS[j])
-	}
-	for j := range gtConstraintsLHS {
-		gtConstraintsLHS[j] = append(gtConstraintsLHS[j], 0.0, gtConstraintsRHS[j])
-	}
-	for j := range ltConstraintsLHS {
-		ltConstraintsLHS[j] = append(ltConstraintsLHS[j], 0.0, ltConstraintsRHS[j])
+	p := problem{
+		sense:     sense,
+		objective: objective,
 	}
 
-	objective = append(objective, 1.0, 0.0)
+	rows := [][]float64{}
 
-	matrix = append(matrix, gtConstraintsLHS...)
-	matrix = append(matrix, ltConstraintsLHS...)
-	matrix = append(matrix, eqConstraintsLHS...)
-	matrix = append(matrix, objective)
-
-	lastRow := len(matrix) - 1
-
-	for _, i := range surplusIndexes {
-		zerofyCell(matrix, lastRow, i)
-	}
-
-	basicRows, basicColumns := findBasics(matrix)
-
-	if !solutionExists(matrix, basicRows, basicColumns, nConstraints) {
-		return []float64{}, 0.0, false
+	add := func(lhs [][]float64, rhs []float64, relation Relation) {
+		for i, row := range lhs {
+			c := p.nConstraints
+			p.nConstraints++
+			if isUnbounding(relation, rhs[i]) {
+				continue
+			}
+			lower, upper := relation.bounds(rhs[i])
+			rows = append(rows, row)
+			p.rowLower = append(p.rowLower, lower)
+			p.rowUpper = append(p.rowUpper, upper)
+			p.constraints = append(p.constraints, c)
+		}
 	}
 
-	saveIndexes := makeSaveIndexes(basicColumns, nConstraints)
+	add(gtConstraintsLHS, gtConstraintsRHS, GE)
+	add(ltConstraintsLHS, ltConstraintsRHS, LE)
+	add(eqConstraintsLHS, eqConstraintsRHS, EQ)
 
-	for {
-		pivotRow, pivotColumn := pivot(matrix, basicColumns)
-		if pivotRow == -1 {
-			break
-		}
+	p.matrix = denseColumns(rows, len(objective))
 
-		handlePivot(matrix, pivotRow, pivotColumn, &saveIndexes)
+	for range objective {
+		p.lower = append(p.lower, 0.0)
+		p.upper = append(p.upper, math.Inf(1))
 	}
 
-	return getResult(matrix, saveIndexes, nVariables)
+	return solveProblem(context.Background(), p, Options{})
 }
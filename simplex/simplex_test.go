@@ -0,0 +1,241 @@
+package simplex
+
+import (
+	"math"
+	"testing"
+)
+
+// near reports whether a and b agree to within 1e-7, relative to their size
+// when that is larger than one. Infinities are near only each other.
+func near(a float64, b float64) bool {
+	if math.IsInf(a, 0) || math.IsInf(b, 0) {
+		return a == b
+	}
+	return math.Abs(a-b) <= 1e-7*math.Max(1.0, math.Max(math.Abs(a), math.Abs(b)))
+}
+
+func nearAll(a []float64, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !near(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func nearRanges(a []Range, b []Range) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !near(a[i].Lower, b[i].Lower) || !near(a[i].Upper, b[i].Upper) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestOptimize(t *testing.T) {
+
+	inf := math.Inf(1)
+
+	tests := []struct {
+		name   string
+		sense  Sense
+		c      []float64
+		gt     [][]float64
+		gtRHS  []float64
+		lt     [][]float64
+		ltRHS  []float64
+		eq     [][]float64
+		eqRHS  []float64
+		status Status
+
+		objective    float64
+		values       []float64
+		duals        []float64
+		reducedCosts []float64
+
+		objectiveRanges []Range
+		lowerRanges     []Range
+		upperRanges     []Range
+	}{
+		{
+			name:  "textbook",
+			sense: Maximization,
+			c:     []float64{3, 5},
+			lt:    [][]float64{{1, 0}, {0, 2}, {3, 2}},
+			ltRHS: []float64{4, 12, 18},
+
+			status:    Optimal,
+			objective: 36,
+			values:    []float64{2, 6},
+			duals:     []float64{0, 1.5, 1},
+
+			reducedCosts:    []float64{0, 0},
+			objectiveRanges: []Range{{0, 7.5}, {2, inf}},
+			lowerRanges:     []Range{{-inf, 2}, {-inf, 12}, {-inf, 18}},
+			upperRanges:     []Range{{2, inf}, {6, 18}, {12, 24}},
+		},
+		{
+			name:  "nonbasic",
+			sense: Maximization,
+			c:     []float64{3, 5, 0.5},
+			lt:    [][]float64{{1, 0, 0}, {0, 2, 0}, {3, 2, 1}},
+			ltRHS: []float64{4, 12, 18},
+
+			status:    Optimal,
+			objective: 36,
+			values:    []float64{2, 6, 0},
+			duals:     []float64{0, 1.5, 1},
+
+			reducedCosts:    []float64{0, 0, -0.5},
+			objectiveRanges: []Range{{1.5, 7.5}, {2, inf}, {-inf, 1}},
+			lowerRanges:     []Range{{-inf, 2}, {-inf, 12}, {-inf, 18}},
+			upperRanges:     []Range{{2, inf}, {6, 18}, {12, 24}},
+		},
+		{
+			name:  "covering",
+			sense: Minimization,
+			c:     []float64{2, 3},
+			gt:    [][]float64{{1, 1}, {1, 3}},
+			gtRHS: []float64{4, 6},
+
+			status:    Optimal,
+			objective: 9,
+			values:    []float64{3, 1},
+			duals:     []float64{1.5, 0.5},
+
+			reducedCosts:    []float64{0, 0},
+			objectiveRanges: []Range{{1, 3}, {2, 6}},
+			lowerRanges:     []Range{{2, 6}, {4, 12}},
+			upperRanges:     []Range{{4, inf}, {6, inf}},
+		},
+		{
+			name:  "equality",
+			sense: Maximization,
+			c:     []float64{1, 1},
+			lt:    [][]float64{{1, 0}},
+			ltRHS: []float64{3},
+			eq:    [][]float64{{1, 2}},
+			eqRHS: []float64{4},
+
+			status:    Optimal,
+			objective: 3.5,
+			values:    []float64{3, 0.5},
+			duals:     []float64{0.5, 0.5},
+
+			reducedCosts:    []float64{0, 0},
+			objectiveRanges: []Range{{0.5, inf}, {-inf, 2}},
+			lowerRanges:     []Range{{-inf, 3}, {3, inf}},
+			upperRanges:     []Range{{0, 4}, {3, inf}},
+		},
+		{
+			name:  "unbounding row",
+			sense: Maximization,
+			c:     []float64{1},
+			lt:    [][]float64{{1}, {1}},
+			ltRHS: []float64{2, inf},
+
+			status:    Optimal,
+			objective: 2,
+			values:    []float64{2},
+			duals:     []float64{1, 0},
+
+			reducedCosts:    []float64{0},
+			objectiveRanges: []Range{{0, inf}},
+		},
+		{
+			name:  "infeasible",
+			sense: Maximization,
+			c:     []float64{1},
+			gt:    [][]float64{{1}},
+			gtRHS: []float64{5},
+			lt:    [][]float64{{1}},
+			ltRHS: []float64{3},
+
+			status: Infeasible,
+		},
+		{
+			name:  "unbounded",
+			sense: Maximization,
+			c:     []float64{1, 0},
+			lt:    [][]float64{{1, -1}},
+			ltRHS: []float64{1},
+
+			status: Unbounded,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			r, e := Optimize(test.sense, test.c, test.gt, test.gtRHS, test.lt, test.ltRHS, test.eq, test.eqRHS)
+			if e != nil {
+				t.Fatal(e)
+			}
+			if r.Status != test.status {
+				t.Fatalf("status %v, want %v", r.Status, test.status)
+			}
+			if r.Status != Optimal {
+				return
+			}
+
+			if !near(r.Objective, test.objective) {
+				t.Errorf("objective %g, want %g", r.Objective, test.objective)
+			}
+			if !nearAll(r.Values, test.values) {
+				t.Errorf("values %v, want %v", r.Values, test.values)
+			}
+			if !nearAll(r.Duals, test.duals) {
+				t.Errorf("duals %v, want %v", r.Duals, test.duals)
+			}
+			if !nearAll(r.ReducedCosts, test.reducedCosts) {
+				t.Errorf("reduced costs %v, want %v", r.ReducedCosts, test.reducedCosts)
+			}
+			if !nearRanges(r.ObjectiveRanges, test.objectiveRanges) {
+				t.Errorf("objective ranges %v, want %v", r.ObjectiveRanges, test.objectiveRanges)
+			}
+			if test.lowerRanges != nil && !nearRanges(r.LowerRanges, test.lowerRanges) {
+				t.Errorf("lower ranges %v, want %v", r.LowerRanges, test.lowerRanges)
+			}
+			if test.upperRanges != nil && !nearRanges(r.UpperRanges, test.upperRanges) {
+				t.Errorf("upper ranges %v, want %v", r.UpperRanges, test.upperRanges)
+			}
+		})
+	}
+}
+
+func TestOptimizeKeepsArguments(t *testing.T) {
+
+	c := []float64{3, 5}
+	lt := [][]float64{{1, 0}, {0, 2}, {3, 2}}
+	rhs := []float64{4, 12, 18}
+
+	for k := 0; k < 2; k++ {
+		r, e := Simplex(c, nil, nil, lt, rhs, nil, nil)
+		if e != nil || r.Status != Optimal || !near(r.Objective, 36) {
+			t.Fatalf("solve %d: %v %v %g", k, e, r.Status, r.Objective)
+		}
+	}
+
+	if !nearAll(c, []float64{3, 5}) || len(lt[0]) != 2 || !nearAll(rhs, []float64{4, 12, 18}) {
+		t.Errorf("arguments changed: %v %v %v", c, lt, rhs)
+	}
+}
+
+func TestOptimizeInvalid(t *testing.T) {
+
+	if _, e := Simplex([]float64{math.NaN()}, nil, nil, nil, nil, nil, nil); e == nil {
+		t.Error("no error for a NaN objective")
+	}
+	if _, e := Simplex([]float64{1, 1}, nil, nil, [][]float64{{1}}, []float64{1}, nil, nil); e == nil {
+		t.Error("no error for a short row")
+	}
+	if _, e := Simplex([]float64{1}, nil, nil, [][]float64{{1}}, []float64{1, 2}, nil, nil); e == nil {
+		t.Error("no error for a right hand side without a row")
+	}
+}
@@ -0,0 +1,77 @@
+package simplex
+
+// sparseMatrix stores the nonzero entries of a matrix column by column.
+// Column j holds rows index[start[j]:start[j+1]] with the matching values.
+type sparseMatrix struct {
+	nRows int
+	start []int
+	index []int
+	value []float64
+}
+
+func newSparseMatrix(nRows int) sparseMatrix {
+	return sparseMatrix{
+		nRows: nRows,
+		start: []int{0},
+	}
+}
+
+// appendColumn adds a column with entries given by rows and their values,
+// skipping zeros.
+func (a *sparseMatrix) appendColumn(rows []int, values []float64) {
+
+	for k, i := range rows {
+		if values[k] != 0.0 {
+			a.index = append(a.index, i)
+			a.value = append(a.value, values[k])
+		}
+	}
+
+	a.start = append(a.start, len(a.index))
+}
+
+func (a *sparseMatrix) nColumns() int {
+	return len(a.start) - 1
+}
+
+// column returns rows and values of the entries of column j.
+func (a *sparseMatrix) column(j int) ([]int, []float64) {
+	return a.index[a.start[j]:a.start[j+1]], a.value[a.start[j]:a.start[j+1]]
+}
+
+// denseColumns stores dense rows of nColumns entries by columns.
+func denseColumns(rows [][]float64, nColumns int) sparseMatrix {
+
+	a := newSparseMatrix(len(rows))
+
+	for j := 0; j < nColumns; j++ {
+		for i, row := range rows {
+			if row[j] != 0.0 {
+				a.index = append(a.index, i)
+				a.value = append(a.value, row[j])
+			}
+		}
+		a.start = append(a.start, len(a.index))
+	}
+
+	return a
+}
+
+// appendRow adds a row with a value for every column, skipping zeros.
+func (a *sparseMatrix) appendRow(values []float64) {
+
+	b := newSparseMatrix(a.nRows + 1)
+
+	for j := 0; j < a.nColumns(); j++ {
+		rows, columnValues := a.column(j)
+		b.index = append(b.index, rows...)
+		b.value = append(b.value, columnValues...)
+		if values[j] != 0.0 {
+			b.index = append(b.index, a.nRows)
+			b.value = append(b.value, values[j])
+		}
+		b.start = append(b.start, len(b.index))
+	}
+
+	*a = b
+}